@@ -6,8 +6,15 @@ import (
 	"strings"
 )
 
+// A Node is an expression or statement. It spans the source range
+// [Pos(), End()), which a FileSet maps back to file:line:col.
+type Node interface {
+	Pos() Pos // position of first character belonging to the node
+	End() Pos // position of first character immediately after the node
+}
+
 type BoolLiteral bool
-type IntLiteral int
+type IntLiteral int64
 type FloatLiteral float64
 type StringLiteral string
 
@@ -30,20 +37,9 @@ func (s StringLiteral) String() string {
 	return string(s)
 }
 
-type Visitor interface {
-	visitBinaryExpr(expr BinaryExpr) string
-	visitGroupingExpr(expr GroupingExpr) string
-	visitLiteralExpr(expr LiteralExpr) string
-	visitUnaryExpr(expr UnaryExpr) string
-}
-
 type AstPrinter struct {
 }
 
-func (p AstPrinter) Print(expr Expr) {
-	fmt.Println(expr.Accept(p))
-}
-
 func (p AstPrinter) parenthesize(name []byte, exprs ...Expr) string {
 	var b strings.Builder
 
@@ -51,28 +47,90 @@ func (p AstPrinter) parenthesize(name []byte, exprs ...Expr) string {
 	b.Write(name)
 	for _, expr := range exprs {
 		b.WriteByte(' ')
-		b.WriteString(expr.Accept(p))
+		b.WriteString(AcceptExpr[string](expr, p))
 	}
 	b.WriteByte(')')
 
 	return b.String()
 }
 
-func (p AstPrinter) visitBinaryExpr(expr BinaryExpr) string {
+func (p AstPrinter) Print(expr Expr) {
+	fmt.Println(AcceptExpr[string](expr, p))
+}
+
+func (p AstPrinter) visitAssignExpr(expr *AssignExpr) string {
+	return p.parenthesize(append([]byte("= "), expr.name.lexeme...), expr.value)
+}
+
+func (p AstPrinter) visitBadExpr(expr *BadExpr) string {
+	return "(bad)"
+}
+
+func (p AstPrinter) visitBinaryExpr(expr *BinaryExpr) string {
 	return p.parenthesize(expr.op.lexeme, expr.lhs, expr.rhs)
 }
 
-func (p AstPrinter) visitGroupingExpr(expr GroupingExpr) string {
+func (p AstPrinter) visitCallExpr(expr *CallExpr) string {
+	return p.parenthesize([]byte("call"), append([]Expr{expr.callee}, expr.args...)...)
+}
+
+func (p AstPrinter) visitConditionalExpr(expr *ConditionalExpr) string {
+	return p.parenthesize([]byte("?:"), expr.condition, expr.thenBranch, expr.elseBranch)
+}
+
+func (p AstPrinter) visitGetExpr(expr *GetExpr) string {
+	return p.parenthesize(append([]byte(". "), expr.name.lexeme...), expr.object)
+}
+
+func (p AstPrinter) visitGroupingExpr(expr *GroupingExpr) string {
 	return p.parenthesize([]byte("group"), expr.expr)
 }
 
-func (p AstPrinter) visitLiteralExpr(expr LiteralExpr) string {
+func (p AstPrinter) visitInterpolationExpr(expr *InterpolationExpr) string {
+	var b strings.Builder
+
+	// Quote the text parts so their boundaries are visible
+	b.WriteString("(interpolate")
+	for _, part := range expr.parts {
+		b.WriteByte(' ')
+		if literal, ok := part.(*LiteralExpr); ok && literal.value != nil {
+			b.WriteString(strconv.Quote(literal.value.String()))
+		} else {
+			b.WriteString(AcceptExpr[string](part, p))
+		}
+	}
+	b.WriteByte(')')
+
+	return b.String()
+}
+
+func (p AstPrinter) visitLiteralExpr(expr *LiteralExpr) string {
 	if expr.value == nil {
 		return "nil"
 	}
 	return expr.value.String()
 }
 
-func (p AstPrinter) visitUnaryExpr(expr UnaryExpr) string {
+func (p AstPrinter) visitLogicalExpr(expr *LogicalExpr) string {
+	return p.parenthesize(expr.op.lexeme, expr.lhs, expr.rhs)
+}
+
+func (p AstPrinter) visitSetExpr(expr *SetExpr) string {
+	return p.parenthesize(append([]byte("= . "), expr.name.lexeme...), expr.object, expr.value)
+}
+
+func (p AstPrinter) visitSuperExpr(expr *SuperExpr) string {
+	return "super." + string(expr.method.lexeme)
+}
+
+func (p AstPrinter) visitThisExpr(expr *ThisExpr) string {
+	return "this"
+}
+
+func (p AstPrinter) visitUnaryExpr(expr *UnaryExpr) string {
 	return p.parenthesize(expr.op.lexeme, expr.rhs)
 }
+
+func (p AstPrinter) visitVariableExpr(expr *VariableExpr) string {
+	return string(expr.name.lexeme)
+}
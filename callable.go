@@ -0,0 +1,89 @@
+package main
+
+import "time"
+
+// http://www.craftinginterpreters.com/functions.html
+
+// A Callable is a runtime value that can be invoked with a call expression.
+type Callable interface {
+	Arity() int
+	Call(interpreter *Interpreter, args []interface{}) interface{}
+	String() string
+}
+
+type LoxFunction struct {
+	declaration   *FunctionStmt
+	closure       *Environment
+	isInitializer bool
+}
+
+func (f *LoxFunction) Arity() int {
+	return len(f.declaration.params)
+}
+
+// bind returns a copy of f whose closure defines "this" as instance.
+func (f *LoxFunction) bind(instance *LoxInstance) *LoxFunction {
+	environment := NewEnvironment(f.closure)
+	environment.define("this", instance)
+	return &LoxFunction{declaration: f.declaration, closure: environment, isInitializer: f.isInitializer}
+}
+
+func (f *LoxFunction) Call(interpreter *Interpreter, args []interface{}) (result interface{}) {
+	environment := NewEnvironment(f.closure)
+	for i, param := range f.declaration.params {
+		environment.define(string(param.lexeme), args[i])
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			ret, ok := r.(Return)
+			if !ok {
+				panic(r)
+			}
+			result = ret.value
+			if f.isInitializer {
+				result = f.closure.getAt(0, "this")
+			}
+		}
+	}()
+	interpreter.executeBlock(f.declaration.body, environment)
+	if f.isInitializer {
+		return f.closure.getAt(0, "this")
+	}
+	return nil
+}
+
+func (f *LoxFunction) String() string {
+	return "<fn " + string(f.declaration.name.lexeme) + ">"
+}
+
+type NativeFunction struct {
+	name  string
+	arity int
+	fn    func(args []interface{}) interface{}
+}
+
+func (f *NativeFunction) Arity() int {
+	return f.arity
+}
+
+func (f *NativeFunction) Call(interpreter *Interpreter, args []interface{}) interface{} {
+	return f.fn(args)
+}
+
+func (f *NativeFunction) String() string {
+	return "<native fn " + f.name + ">"
+}
+
+// Return unwinds the Go stack from a return statement to the enclosing
+// LoxFunction.Call, the same way a bailout unwinds the parser to the enclosing
+// declaration.
+type Return struct {
+	value interface{}
+}
+
+var natives = []*NativeFunction{
+	{name: "clock", arity: 0, fn: func(args []interface{}) interface{} {
+		return FloatLiteral(float64(time.Now().UnixNano()) / float64(time.Second))
+	}},
+}
@@ -0,0 +1,62 @@
+package main
+
+// http://www.craftinginterpreters.com/classes.html
+
+type LoxClass struct {
+	name       string
+	superclass *LoxClass
+	methods    map[string]*LoxFunction
+}
+
+func (c *LoxClass) Arity() int {
+	if initializer := c.findMethod("init"); initializer != nil {
+		return initializer.Arity()
+	}
+	return 0
+}
+
+func (c *LoxClass) Call(interpreter *Interpreter, args []interface{}) interface{} {
+	instance := &LoxInstance{class: c, fields: make(map[string]interface{})}
+	if initializer := c.findMethod("init"); initializer != nil {
+		initializer.bind(instance).Call(interpreter, args)
+	}
+	return instance
+}
+
+func (c *LoxClass) findMethod(name string) *LoxFunction {
+	if method, ok := c.methods[name]; ok {
+		return method
+	}
+	if c.superclass != nil {
+		return c.superclass.findMethod(name)
+	}
+	return nil
+}
+
+func (c *LoxClass) String() string {
+	return c.name
+}
+
+type LoxInstance struct {
+	class  *LoxClass
+	fields map[string]interface{}
+}
+
+func (i *LoxInstance) get(name Token) interface{} {
+	key := string(name.lexeme)
+	if value, ok := i.fields[key]; ok {
+		return value
+	}
+	if method := i.class.findMethod(key); method != nil {
+		return method.bind(i)
+	}
+	panic(RuntimeError{token: name, code: ErrUndefinedProperty, message: "Undefined property '" + key + "'."})
+}
+
+func (i *LoxInstance) set(name Token, value interface{}) {
+	i.fields[string(name.lexeme)] = value
+}
+
+func (i *LoxInstance) String() string {
+	return i.class.name + " instance"
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// A CSTElement is a *CSTNode or a *CSTToken.
+type CSTElement interface {
+	cstElement()
+}
+
+// A CSTNode is a node of a lossless concrete syntax tree. Unlike the syntax
+// tree built by Parser it keeps every token, along with the whitespace and
+// comments around it, so printing it reproduces the source byte for byte.
+// Each node covers a syntax tree node, except the root, which covers the
+// whole file.
+type CSTNode struct {
+	node     Node // nil for the root
+	children []CSTElement
+}
+
+// A CSTToken is a leaf of a concrete syntax tree: a token together with its
+// leading and trailing trivia, as split by Scanner.Trivia.
+type CSTToken struct {
+	token    Token
+	leading  []Trivia
+	trailing []Trivia
+}
+
+func (*CSTNode) cstElement()  {}
+func (*CSTToken) cstElement() {}
+
+// ParseCST parses the content of file, read from src, into a concrete syntax
+// tree. Syntax errors don't stop the tree from being built; tokens that
+// aren't part of any well-formed statement hang off the root.
+func ParseCST(file *File, src io.Reader) (*CSTNode, ErrorList) {
+	parser, stmts, errs := parseFile(file, src, ScanTrivia, false)
+	return buildCST(stmts, parser.leaves), errs
+}
+
+// buildCST nests leaves, which are in source order, under the innermost
+// syntax tree node from stmts whose span contains them.
+func buildCST(stmts []Stmt, leaves []*CSTToken) *CSTNode {
+	// Collect the nodes in source order, outermost first
+	var nodes []Node
+	for _, stmt := range stmts {
+		Inspect(stmt, func(n Node) bool {
+			if !synthesized(n) {
+				nodes = append(nodes, n)
+			}
+			return true
+		})
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Pos() != nodes[j].Pos() {
+			return nodes[i].Pos() < nodes[j].Pos()
+		}
+		return nodes[i].End() > nodes[j].End()
+	})
+
+	root := &CSTNode{}
+	stack := []*CSTNode{root}
+	add := func(child CSTElement) {
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, child)
+	}
+	closeBefore := func(pos Pos) {
+		for len(stack) > 1 && stack[len(stack)-1].node.End() <= pos {
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	for _, leaf := range leaves {
+		for len(nodes) > 0 && nodes[0].Pos() <= leaf.token.pos {
+			closeBefore(nodes[0].Pos())
+			node := &CSTNode{node: nodes[0]}
+			add(node)
+			stack = append(stack, node)
+			nodes = nodes[1:]
+		}
+		closeBefore(leaf.token.pos)
+		add(leaf)
+	}
+	return root
+}
+
+// synthesized reports whether n was made up by the parser rather than
+// written in the source, like the pieces of a desugared for loop.
+func synthesized(n Node) bool {
+	switch n := n.(type) {
+	case *BlockStmt:
+		return !n.lbrace.IsValid()
+	case *ExpressionStmt:
+		return !n.semicolon.IsValid()
+	}
+	return !n.Pos().IsValid()
+}
+
+// Text returns the source text n covers, including whitespace and comments.
+// For the root that is the whole file.
+func (n *CSTNode) Text() string {
+	var b strings.Builder
+	n.writeTo(&b)
+	return b.String()
+}
+
+func (n *CSTNode) writeTo(b *strings.Builder) {
+	for _, child := range n.children {
+		switch child := child.(type) {
+		case *CSTNode:
+			child.writeTo(b)
+		case *CSTToken:
+			child.writeTo(b)
+		}
+	}
+}
+
+func (t *CSTToken) writeTo(b *strings.Builder) {
+	for _, trivia := range t.leading {
+		b.WriteString(trivia.text)
+	}
+	b.Write(t.token.lexeme)
+	for _, trivia := range t.trailing {
+		b.WriteString(trivia.text)
+	}
+}
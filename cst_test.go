@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseCSTRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		wantErr bool
+	}{
+		{name: "empty", src: ""},
+		{name: "BOM", src: "\xef\xbb\xbfprint 1;\n"},
+		{name: "CRLF", src: "var x = 1;\r\n// comment\r\nprint x;\r\n"},
+		{name: "comments", src: "/* a /* nested */ b */ print 1; // end\n\n\n// last"},
+		{name: "interpolation", src: "var x = 1;\nprint \"a ${x + 1} b ${\"c ${x}\"} d\";\n"},
+		{name: "for loop", src: "for (var i = 0; i < 3; i = i + 1) { print i; }\n"},
+		{name: "unterminated block comment", src: "print 1;\n/* open /* nested */\nprint 2;\n", wantErr: true},
+		{name: "syntax errors", src: "var = 1;\nprint (1 + ;\n{ print 2 }\n}\nprint 3;\n", wantErr: true},
+		{name: "illegal characters", src: "print 1 # 2;\nprint @;\n", wantErr: true},
+		{name: "unterminated string", src: "print \"open ${x};\n", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := NewFileSet().AddFile(test.name, -1, len(test.src))
+			cst, errs := ParseCST(file, strings.NewReader(test.src))
+			if got := errs.HasErrors(); got != test.wantErr {
+				t.Errorf("HasErrors() = %v, want %v (errors: %v)", got, test.wantErr, errs)
+			}
+			if got := cst.Text(); got != test.src {
+				t.Errorf("Text() = %q, want %q", got, test.src)
+			}
+		})
+	}
+}
+
+func TestParseCSTNesting(t *testing.T) {
+	src := "for (var i = 0; i < 3; i = i + 1) print i;\n" +
+		"fn f(n) {\n  if (n) { return n; }\n  return -1;\n}\n" +
+		"class C < B { m() { print this.x; } }\n"
+	tests := []struct {
+		text   string // of the node, without surrounding trivia
+		parent string // type of its parent's node, "" for the root
+	}{
+		{"var i = 0;", "*main.WhileStmt"},
+		{"i < 3", "*main.WhileStmt"},
+		{"i = i + 1", "*main.WhileStmt"},
+		{"print i;", "*main.WhileStmt"},
+		{"return n;", "*main.BlockStmt"},
+		{"{ return n; }", "*main.IfStmt"},
+		{"return -1;", "*main.FunctionStmt"},
+		{"-1", "*main.ReturnStmt"},
+		{"print this.x;", "*main.FunctionStmt"},
+		{"this.x", "*main.PrintStmt"},
+		{"B", "*main.ClassStmt"},
+	}
+
+	file := NewFileSet().AddFile("nesting", -1, len(src))
+	cst, errs := ParseCST(file, strings.NewReader(src))
+	if errs.HasErrors() {
+		t.Fatalf("ParseCST errors: %v", errs)
+	}
+	if len(cst.children) != 4 {
+		t.Errorf("root has %d children, want 3 statements and EOF", len(cst.children))
+	}
+	for _, test := range tests {
+		parent, ok := cstParent(cst, test.text)
+		if !ok {
+			t.Errorf("no node %q", test.text)
+			continue
+		}
+		got := ""
+		if parent != nil {
+			got = fmt.Sprintf("%T", parent)
+		}
+		if got != test.parent {
+			t.Errorf("parent of %q is %q, want %q", test.text, got, test.parent)
+		}
+	}
+}
+
+// cstParent returns the node of the parent of the first node, in source
+// order, whose text is text.
+func cstParent(n *CSTNode, text string) (parent Node, ok bool) {
+	for _, child := range n.children {
+		if child, isNode := child.(*CSTNode); isNode {
+			if strings.TrimSpace(child.Text()) == text {
+				return n.node, true
+			}
+			if parent, ok := cstParent(child, text); ok {
+				return parent, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Diagnostic codes, grouped by the phase that reports them.
+const (
+	// Scanner
+	ErrUnexpectedChar      = "E0101"
+	ErrUnterminatedString  = "E0102"
+	ErrInvalidUTF8         = "E0103"
+	ErrInvalidNumber       = "E0104"
+	ErrNumberOverflow      = "E0105"
+	ErrInvalidEscape       = "E0106"
+	ErrUnterminatedComment = "E0107"
+	ErrReadSource          = "E0108"
+
+	// Parser
+	ErrExpectedToken       = "E0201"
+	ErrExpectedExpr        = "E0202"
+	ErrInvalidAssignTarget = "E0203"
+	ErrTooManyArgs         = "E0204"
+	ErrMissingOperand      = "E0205"
+	ErrUnmatchedBrace      = "E0206"
+
+	// Resolver
+	ErrOwnInitializer    = "E0301"
+	ErrTopLevelReturn    = "E0302"
+	ErrRedeclared        = "E0303"
+	ErrInitializerReturn = "E0304"
+	ErrThisOutsideClass  = "E0305"
+	ErrSuperOutsideClass = "E0306"
+	ErrSuperNoSuperclass = "E0307"
+	ErrInheritFromSelf   = "E0308"
+
+	// Interpreter
+	ErrOperandType        = "E0401"
+	ErrUndefinedVariable  = "E0402"
+	ErrNotCallable        = "E0403"
+	ErrArity              = "E0404"
+	ErrUndefinedProperty  = "E0405"
+	ErrNotInstance        = "E0406"
+	ErrSuperclassNotClass = "E0407"
+	ErrIntegerOverflow    = "E0408"
+	ErrStackOverflow      = "E0409"
+
+	// Vet
+	ErrUnknownRule        = "E0501"
+	WarnUnusedVariable    = "W0501"
+	WarnUnreachableCode   = "W0502"
+	WarnSelfComparison    = "W0503"
+	WarnConstantCondition = "W0504"
+	WarnShadowedVariable  = "W0505"
+	WarnMixedComparison   = "W0506"
+	WarnDivisionByZero    = "W0507"
+)
+
+// A Diagnostic is a message about the source span [Pos, End).
+type Diagnostic struct {
+	Severity LogLevel
+	Code     string
+	Pos      Pos
+	End      Pos
+	Message  string
+	Notes    []string
+}
+
+// Error implements the error interface.
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s[%s]: %s", LogLevelConfig[d.Severity].level, d.Code, d.Message)
+}
+
+// ErrorList is a list of *Diagnostics.
+// The zero value for an ErrorList is an empty ErrorList ready to use.
+type ErrorList []*Diagnostic
+
+// Add adds a Diagnostic to an ErrorList.
+func (p *ErrorList) Add(d *Diagnostic) {
+	*p = append(*p, d)
+}
+
+// Reset resets an ErrorList to no errors.
+func (p *ErrorList) Reset() { *p = (*p)[0:0] }
+
+// ErrorList implements the sort Interface.
+func (p ErrorList) Len() int           { return len(p) }
+func (p ErrorList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p ErrorList) Less(i, j int) bool { return p[i].Pos < p[j].Pos }
+
+// Sort sorts an ErrorList by source position.
+func (p ErrorList) Sort() {
+	sort.Stable(p)
+}
+
+// HasErrors reports whether the list holds any diagnostic of Error severity.
+func (p ErrorList) HasErrors() bool {
+	for _, d := range p {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// An ErrorList implements the error interface.
+func (p ErrorList) Error() string {
+	switch len(p) {
+	case 0:
+		return "no errors"
+	case 1:
+		return p[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", p[0], len(p)-1)
+}
+
+// Err returns an error equivalent to this error list.
+// If the list is empty, Err returns nil.
+func (p ErrorList) Err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// diffMaxCost bounds the search for a shortest edit script: parts of the
+// input whose shortest script needs more than twice as many edits are shown
+// as replaced outright, which keeps diffing large rewrites fast.
+const diffMaxCost = 256
+
+// A diffLine is a line of an edit script: kept (' '), deleted ('-') or
+// inserted ('+').
+type diffLine struct {
+	op   byte
+	text []byte
+}
+
+// unifiedDiff returns the differences between a and b in unified diff format,
+// or nil if there are none.
+func unifiedDiff(oldName, newName string, a, b []byte) []byte {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out bytes.Buffer
+	for i := 0; i < len(lines); {
+		// Find the next change and the end of its hunk, which takes in later
+		// changes separated from it by no more than twice the context
+		for i < len(lines) && lines[i].op == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		start := max(i-diffContext, 0)
+		end := i
+		for end < len(lines) {
+			if lines[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].op == ' ' {
+				run++
+			}
+			if run == len(lines) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(lines))
+				break
+			}
+			end = run
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		oldStart, newStart := 1, 1
+		for _, line := range lines[:start] {
+			if line.op != '+' {
+				oldStart++
+			}
+			if line.op != '-' {
+				newStart++
+			}
+		}
+		oldLen, newLen := 0, 0
+		for _, line := range lines[start:end] {
+			if line.op != '+' {
+				oldLen++
+			}
+			if line.op != '-' {
+				newLen++
+			}
+		}
+		// An empty range names the line before it
+		if oldLen == 0 {
+			oldStart--
+		}
+		if newLen == 0 {
+			newStart--
+		}
+
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", oldStart, oldLen, newStart, newLen)
+		for _, line := range lines[start:end] {
+			out.WriteByte(line.op)
+			out.Write(line.text)
+			if !bytes.HasSuffix(line.text, []byte("\n")) {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+// diffLines returns a shortest edit script turning a into b. Within each run
+// of changed lines the deletions come first.
+func diffLines(a, b [][]byte) []diffLine {
+	var lines []diffLine
+	diff(a, b, &lines)
+
+	for i := 0; i < len(lines); {
+		if lines[i].op == ' ' {
+			i++
+			continue
+		}
+		end := i
+		for end < len(lines) && lines[end].op != ' ' {
+			end++
+		}
+		sort.SliceStable(lines[i:end], func(j, k int) bool {
+			return lines[i+j].op == '-' && lines[i+k].op == '+'
+		})
+		i = end
+	}
+	return lines
+}
+
+// diff appends a shortest edit script turning a into b to lines. It uses the
+// linear space variant of Myers' algorithm: bisect splits the problem where
+// the shortest path crosses its middle, and each half is solved recursively.
+func diff(a, b [][]byte, lines *[]diffLine) {
+	// Lines common to both ends are kept as they are
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && bytes.Equal(a[prefix], b[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		bytes.Equal(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		*lines = append(*lines, diffLine{' ', line})
+	}
+	defer func(common [][]byte) {
+		for _, line := range common {
+			*lines = append(*lines, diffLine{' ', line})
+		}
+	}(a[len(a)-suffix:])
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	x, y, ok := bisect(a, b)
+	if !ok {
+		for _, line := range a {
+			*lines = append(*lines, diffLine{'-', line})
+		}
+		for _, line := range b {
+			*lines = append(*lines, diffLine{'+', line})
+		}
+		return
+	}
+	diff(a[:x], b[:y], lines)
+	diff(a[x:], b[y:], lines)
+}
+
+// bisect returns a point (x, y) that a shortest edit script turning a into b
+// passes through, found by searching from both ends at once until the paths
+// meet. ok is false if there is no such point other than the ends, as when a
+// or b is empty or they have no line in common, or if finding it would cost
+// more than diffMaxCost.
+func bisect(a, b [][]byte) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return 0, 0, false
+	}
+	maxD := min((n+m+1)/2, diffMaxCost)
+	offset := maxD
+	// vf[offset+k] is the furthest x reached on diagonal k = x-y going
+	// forward, vb the same going backward from (n, m) in reversed coordinates
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+
+	delta := n - m
+	// When delta is odd the paths can only meet after a forward step
+	front := delta%2 != 0
+	// Diagonals that ran off the edit graph don't need searching again
+	kfStart, kfEnd, kbStart, kbEnd := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + kfStart; k <= d-kfEnd; k += 2 {
+			var x int
+			if k == -d || k != d && vf[offset+k-1] < vf[offset+k+1] {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(a[x], b[y]) {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			switch {
+			case x > n:
+				kfEnd += 2
+			case y > m:
+				kfStart += 2
+			case front:
+				if i := offset + delta - k; i >= 0 && i < len(vb) && vb[i] != -1 && x >= n-vb[i] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + kbStart; k <= d-kbEnd; k += 2 {
+			var x int
+			if k == -d || k != d && vb[offset+k-1] < vb[offset+k+1] {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(a[n-1-x], b[m-1-y]) {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+			switch {
+			case x > n:
+				kbEnd += 2
+			case y > m:
+				kbStart += 2
+			case !front:
+				if i := offset + delta - k; i >= 0 && i < len(vf) && vf[i] != -1 {
+					xf := vf[i]
+					if yf := xf - (delta - k); xf >= n-x {
+						return xf, yf, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// splitLines splits b after each newline.
+func splitLines(b []byte) [][]byte {
+	var lines [][]byte
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n') + 1
+		if i == 0 {
+			i = len(b)
+		}
+		lines = append(lines, b[:i])
+		b = b[i:]
+	}
+	return lines
+}
@@ -0,0 +1,56 @@
+package main
+
+// http://www.craftinginterpreters.com/statements-and-state.html#environments
+
+type Environment struct {
+	enclosing *Environment
+	values    map[string]interface{}
+}
+
+func NewEnvironment(enclosing *Environment) *Environment {
+	return &Environment{enclosing: enclosing, values: make(map[string]interface{})}
+}
+
+func (e *Environment) ancestor(distance int) *Environment {
+	environment := e
+	for i := 0; i < distance; i++ {
+		environment = environment.enclosing
+	}
+	return environment
+}
+
+func (e *Environment) assign(name Token, value interface{}) {
+	key := string(name.lexeme)
+	if _, ok := e.values[key]; ok {
+		e.values[key] = value
+		return
+	}
+	if e.enclosing != nil {
+		e.enclosing.assign(name, value)
+		return
+	}
+	panic(RuntimeError{token: name, code: ErrUndefinedVariable, message: "Cannot assign to undeclared variable '" + key + "'."})
+}
+
+func (e *Environment) assignAt(distance int, name Token, value interface{}) {
+	e.ancestor(distance).values[string(name.lexeme)] = value
+}
+
+func (e *Environment) define(name string, value interface{}) {
+	e.values[name] = value
+}
+
+func (e *Environment) get(name Token) interface{} {
+	key := string(name.lexeme)
+	if value, ok := e.values[key]; ok {
+		return value
+	}
+	if e.enclosing != nil {
+		return e.enclosing.get(name)
+	}
+	panic(RuntimeError{token: name, code: ErrUndefinedVariable, message: "Undefined variable '" + key + "'."})
+}
+
+func (e *Environment) getAt(distance int, name string) interface{} {
+	return e.ancestor(distance).values[name]
+}
@@ -1,7 +1,22 @@
 package main
 
+import "fmt"
+
 type Expr interface {
-	Accept(v Visitor) string
+	Node
+	exprNode()
+}
+
+type AssignExpr struct {
+	name  Token
+	value Expr
+}
+
+// A BadExpr is a placeholder for an expression containing syntax errors for
+// which no correct expression node can be created.
+type BadExpr struct {
+	from Pos
+	to   Pos
 }
 
 type BinaryExpr struct {
@@ -10,31 +25,175 @@ type BinaryExpr struct {
 	rhs Expr
 }
 
+type CallExpr struct {
+	callee Expr
+	lparen Token
+	args   []Expr
+	rparen Token
+}
+
+type ConditionalExpr struct {
+	condition  Expr
+	thenBranch Expr
+	elseBranch Expr
+}
+
+type GetExpr struct {
+	object Expr
+	name   Token
+}
+
 type GroupingExpr struct {
-	expr Expr
+	lparen Pos
+	expr   Expr
+	rparen Pos
+}
+
+// An InterpolationExpr is a string with embedded expressions. Its parts
+// alternate between LiteralExprs for the text, whose tokens include the
+// quotes and "${" / "}" delimiters, and the embedded expressions.
+type InterpolationExpr struct {
+	parts []Expr
 }
 
 type LiteralExpr struct {
+	token Token // NoPos for literals synthesized by the parser
 	value Literal
 }
 
-type UnaryExpr struct {
+type LogicalExpr struct {
 	op  Token
+	lhs Expr
 	rhs Expr
 }
 
-func (expr BinaryExpr) Accept(v Visitor) string {
-	return v.visitBinaryExpr(expr)
+type SetExpr struct {
+	object Expr
+	name   Token
+	value  Expr
+}
+
+type SuperExpr struct {
+	keyword Token
+	method  Token
 }
 
-func (expr GroupingExpr) Accept(v Visitor) string {
-	return v.visitGroupingExpr(expr)
+type ThisExpr struct {
+	keyword Token
 }
 
-func (expr LiteralExpr) Accept(v Visitor) string {
-	return v.visitLiteralExpr(expr)
+type UnaryExpr struct {
+	op  Token
+	rhs Expr
+}
+
+type VariableExpr struct {
+	name Token
+}
+
+func (*AssignExpr) exprNode()        {}
+func (*BadExpr) exprNode()           {}
+func (*BinaryExpr) exprNode()        {}
+func (*CallExpr) exprNode()          {}
+func (*ConditionalExpr) exprNode()   {}
+func (*GetExpr) exprNode()           {}
+func (*GroupingExpr) exprNode()      {}
+func (*InterpolationExpr) exprNode() {}
+func (*LiteralExpr) exprNode()       {}
+func (*LogicalExpr) exprNode()       {}
+func (*SetExpr) exprNode()           {}
+func (*SuperExpr) exprNode()         {}
+func (*ThisExpr) exprNode()          {}
+func (*UnaryExpr) exprNode()         {}
+func (*VariableExpr) exprNode()      {}
+
+func (expr *AssignExpr) Pos() Pos        { return expr.name.pos }
+func (expr *BadExpr) Pos() Pos           { return expr.from }
+func (expr *BinaryExpr) Pos() Pos        { return expr.lhs.Pos() }
+func (expr *CallExpr) Pos() Pos          { return expr.callee.Pos() }
+func (expr *ConditionalExpr) Pos() Pos   { return expr.condition.Pos() }
+func (expr *GetExpr) Pos() Pos           { return expr.object.Pos() }
+func (expr *GroupingExpr) Pos() Pos      { return expr.lparen }
+func (expr *InterpolationExpr) Pos() Pos { return expr.parts[0].Pos() }
+func (expr *LiteralExpr) Pos() Pos       { return expr.token.pos }
+func (expr *LogicalExpr) Pos() Pos       { return expr.lhs.Pos() }
+func (expr *SetExpr) Pos() Pos           { return expr.object.Pos() }
+func (expr *SuperExpr) Pos() Pos         { return expr.keyword.pos }
+func (expr *ThisExpr) Pos() Pos          { return expr.keyword.pos }
+func (expr *UnaryExpr) Pos() Pos         { return expr.op.pos }
+func (expr *VariableExpr) Pos() Pos      { return expr.name.pos }
+
+func (expr *AssignExpr) End() Pos        { return expr.value.End() }
+func (expr *BadExpr) End() Pos           { return expr.to }
+func (expr *BinaryExpr) End() Pos        { return expr.rhs.End() }
+func (expr *CallExpr) End() Pos          { return expr.rparen.end }
+func (expr *ConditionalExpr) End() Pos   { return expr.elseBranch.End() }
+func (expr *GetExpr) End() Pos           { return expr.name.end }
+func (expr *GroupingExpr) End() Pos      { return expr.rparen + 1 }
+func (expr *InterpolationExpr) End() Pos { return expr.parts[len(expr.parts)-1].End() }
+func (expr *LiteralExpr) End() Pos       { return expr.token.end }
+func (expr *LogicalExpr) End() Pos       { return expr.rhs.End() }
+func (expr *SetExpr) End() Pos           { return expr.value.End() }
+func (expr *SuperExpr) End() Pos         { return expr.method.end }
+func (expr *ThisExpr) End() Pos          { return expr.keyword.end }
+func (expr *UnaryExpr) End() Pos         { return expr.rhs.End() }
+func (expr *VariableExpr) End() Pos      { return expr.name.end }
+
+// An ExprVisitor is a pass over expressions producing a result of type R,
+// e.g. a string for AstPrinter or a runtime value for Interpreter.
+type ExprVisitor[R any] interface {
+	visitAssignExpr(expr *AssignExpr) R
+	visitBadExpr(expr *BadExpr) R
+	visitBinaryExpr(expr *BinaryExpr) R
+	visitCallExpr(expr *CallExpr) R
+	visitConditionalExpr(expr *ConditionalExpr) R
+	visitGetExpr(expr *GetExpr) R
+	visitGroupingExpr(expr *GroupingExpr) R
+	visitInterpolationExpr(expr *InterpolationExpr) R
+	visitLiteralExpr(expr *LiteralExpr) R
+	visitLogicalExpr(expr *LogicalExpr) R
+	visitSetExpr(expr *SetExpr) R
+	visitSuperExpr(expr *SuperExpr) R
+	visitThisExpr(expr *ThisExpr) R
+	visitUnaryExpr(expr *UnaryExpr) R
+	visitVariableExpr(expr *VariableExpr) R
 }
 
-func (expr UnaryExpr) Accept(v Visitor) string {
-	return v.visitUnaryExpr(expr)
+// AcceptExpr dispatches expr to the matching method of v and returns its
+// result. Go methods cannot have type parameters, so this replaces the
+// per-node Accept methods of the book's Java implementation.
+func AcceptExpr[R any](expr Expr, v ExprVisitor[R]) R {
+	switch expr := expr.(type) {
+	case *AssignExpr:
+		return v.visitAssignExpr(expr)
+	case *BadExpr:
+		return v.visitBadExpr(expr)
+	case *BinaryExpr:
+		return v.visitBinaryExpr(expr)
+	case *CallExpr:
+		return v.visitCallExpr(expr)
+	case *ConditionalExpr:
+		return v.visitConditionalExpr(expr)
+	case *GetExpr:
+		return v.visitGetExpr(expr)
+	case *GroupingExpr:
+		return v.visitGroupingExpr(expr)
+	case *InterpolationExpr:
+		return v.visitInterpolationExpr(expr)
+	case *LiteralExpr:
+		return v.visitLiteralExpr(expr)
+	case *LogicalExpr:
+		return v.visitLogicalExpr(expr)
+	case *SetExpr:
+		return v.visitSetExpr(expr)
+	case *SuperExpr:
+		return v.visitSuperExpr(expr)
+	case *ThisExpr:
+		return v.visitThisExpr(expr)
+	case *UnaryExpr:
+		return v.visitUnaryExpr(expr)
+	case *VariableExpr:
+		return v.visitVariableExpr(expr)
+	}
+	panic(fmt.Sprintf("unexpected expression type %T", expr))
 }
@@ -86,11 +86,14 @@ func (f *File) Offset(p Pos) int {
 //
 func (f *File) Line(p Pos) (line Line) {
 	pos := f.Position(p)
+	if !pos.IsValid() {
+		return
+	}
 	line.Filename = pos.Filename
-	line.Offset = f.Lines[pos.Line]
+	line.Offset = f.Lines[pos.Line-1]
 	line.Line = pos.Line
-	if len(f.Lines) > line.Line {
-		line.Length = f.Lines[line.Line+1]
+	if pos.Line < len(f.Lines) {
+		line.Length = f.Lines[pos.Line] - line.Offset
 	} else {
 		line.Length = f.Size - line.Offset
 	}
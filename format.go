@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxWidth is the line length past which argument and parameter lists are
+// wrapped, one item per line, and binary expressions are broken after an
+// operator.
+const maxWidth = 80
+
+// indentString is one level of indentation.
+const indentString = "  "
+
+// Format parses the content of file, read from src, and returns it printed
+// in the canonical style. The source is not formatted if it has syntax
+// errors, which are returned instead.
+func Format(file *File, src io.Reader) ([]byte, ErrorList) {
+	parser, stmts, errs := parseFile(file, src, ScanTrivia, false)
+	if errs.HasErrors() {
+		return nil, errs
+	}
+
+	p := &printer{leaves: parser.leaves, atLineStart: true, led: -1}
+	for _, stmt := range stmts {
+		p.stmt(stmt)
+	}
+	p.leading() // comments at the end of the file
+	p.newline()
+	return p.out.Bytes(), errs
+}
+
+// A printer prints a syntax tree in the canonical style. It prints exactly
+// the tokens of the source, in order, and only changes the whitespace between
+// them, so each comment can be put back next to the token it came with.
+type printer struct {
+	out    bytes.Buffer
+	leaves []*CSTToken
+	cursor int // index in leaves of the next token to print
+	led    int // index in leaves of the last token whose leading comments were printed
+
+	atLineStart bool // nothing has been written on the current line yet
+	breakLine   bool // the next token must start a new line
+	col         int  // display width of the current line
+	continued   bool // the current line continues a statement, so is indented one more level
+	indent      int
+	measuring   bool // only measuring the width of the output; nothing is written
+	space       bool // write a space before the next token
+}
+
+// block prints "{", n declarations printed by each, and "}". The
+// declarations go on lines of their own, indented.
+func (p *printer) block(n int, each func(i int)) {
+	p.space = true
+	p.token(LEFT_BRACE)
+	if n == 0 && !p.breakLine && !p.hasLeadingComments() {
+		p.token(RIGHT_BRACE)
+		return
+	}
+
+	p.indent++
+	p.newline()
+	p.continued = false
+	for i := 0; i < n; i++ {
+		each(i)
+	}
+	p.leading()
+	p.indent--
+	p.newline()
+	p.token(RIGHT_BRACE)
+}
+
+// blankLine ends the current line and adds an empty one, except at the start
+// of the file or of a block, or after another empty line.
+func (p *printer) blankLine() {
+	p.newline()
+	b := p.out.Bytes()
+	if len(b) == 0 || bytes.HasSuffix(b, []byte("{\n")) || bytes.HasSuffix(b, []byte("\n\n")) {
+		return
+	}
+	p.out.WriteByte('\n')
+}
+
+// binary prints a binary or logical expression. If the right operand doesn't
+// fit on the current line it goes on the next one.
+func (p *printer) binary(lhs Expr, op TokenKind, rhs Expr) {
+	p.expr(lhs)
+	p.operator(op)
+	if !p.measuring && p.col+1+p.measure(func() { p.expr(rhs) }) > maxWidth {
+		p.breakLine = true
+	}
+	p.expr(rhs)
+}
+
+// body prints the body of an if or a loop on the same line, except for an
+// if-else, which goes on a line of its own so the indentation shows which
+// 'if' the 'else' belongs to.
+func (p *printer) body(stmt Stmt) {
+	if s, ok := stmt.(*IfStmt); ok && s.elseBranch != nil {
+		p.indent++
+		p.newline()
+		p.continued = false
+		p.node(s)
+		p.indent--
+		return
+	}
+	p.space = true
+	p.node(stmt)
+}
+
+// comment prints a comment. An ownLine comment had a line to itself in the
+// source, or at least began one; newlineAfter says the source line ended after
+// it.
+func (p *printer) comment(text string, ownLine, newlineAfter bool) {
+	if ownLine && !p.atLineStart {
+		p.newline()
+		p.continued = true
+	}
+	if !ownLine {
+		p.space = true
+	}
+	p.write(text)
+
+	switch {
+	case newlineAfter && ownLine:
+		p.newline()
+	case newlineAfter:
+		p.breakLine = true
+	default:
+		p.space = true
+	}
+}
+
+func (p *printer) expr(expr Expr) {
+	switch e := expr.(type) {
+	case *AssignExpr:
+		p.token(IDENTIFIER)
+		p.operator(EQUAL)
+		p.expr(e.value)
+	case *BinaryExpr:
+		if e.op.kind != COMMA {
+			p.binary(e.lhs, e.op.kind, e.rhs)
+			break
+		}
+		p.expr(e.lhs)
+		p.token(COMMA)
+		p.space = true
+		p.expr(e.rhs)
+	case *CallExpr:
+		p.expr(e.callee)
+		p.token(LEFT_PAREN)
+		p.list(len(e.args), func(i int) { p.expr(e.args[i]) })
+		p.token(RIGHT_PAREN)
+	case *ConditionalExpr:
+		p.expr(e.condition)
+		p.operator(QUESTION)
+		p.expr(e.thenBranch)
+		p.operator(COLON)
+		p.expr(e.elseBranch)
+	case *GetExpr:
+		p.expr(e.object)
+		p.token(DOT)
+		p.token(IDENTIFIER)
+	case *GroupingExpr:
+		p.token(LEFT_PAREN)
+		p.expr(e.expr)
+		p.token(RIGHT_PAREN)
+	case *InterpolationExpr:
+		// The text parts' tokens include the "${" and "}" around the
+		// expressions, so nothing goes between them
+		for i, part := range e.parts {
+			if i%2 == 0 {
+				p.token(part.(*LiteralExpr).token.kind)
+			} else {
+				p.expr(part)
+			}
+		}
+	case *LiteralExpr:
+		p.token(e.token.kind)
+	case *LogicalExpr:
+		p.binary(e.lhs, e.op.kind, e.rhs)
+	case *SetExpr:
+		p.expr(e.object)
+		p.token(DOT)
+		p.token(IDENTIFIER)
+		p.operator(EQUAL)
+		p.expr(e.value)
+	case *SuperExpr:
+		p.token(SUPER)
+		p.token(DOT)
+		p.token(IDENTIFIER)
+	case *ThisExpr:
+		p.token(THIS)
+	case *UnaryExpr:
+		p.token(e.op.kind)
+		p.expr(e.rhs)
+	case *VariableExpr:
+		p.token(IDENTIFIER)
+	default:
+		panic(fmt.Sprintf("format: unexpected expression type %T", e))
+	}
+}
+
+// forStmt prints loop, the desugared form of a for loop, along with its
+// initializer if it has one.
+func (p *printer) forStmt(initializer Stmt, loop *WhileStmt) {
+	body := loop.body
+	var increment Expr
+	if block, ok := body.(*BlockStmt); ok && synthesized(block) {
+		body = block.stmts[0]
+		increment = block.stmts[1].(*ExpressionStmt).expr
+	}
+
+	p.token(FOR)
+	p.space = true
+	p.token(LEFT_PAREN)
+	if initializer != nil {
+		p.node(initializer)
+	} else {
+		p.token(SEMICOLON)
+	}
+	if !synthesized(loop.condition) {
+		p.space = true
+		p.expr(loop.condition)
+	}
+	p.token(SEMICOLON)
+	if increment != nil {
+		p.space = true
+		p.expr(increment)
+	}
+	p.token(RIGHT_PAREN)
+	p.body(body)
+}
+
+func (p *printer) function(stmt *FunctionStmt) {
+	if stmt.keyword.pos.IsValid() {
+		p.token(FN)
+		p.space = true
+	}
+	p.token(IDENTIFIER)
+	p.token(LEFT_PAREN)
+	p.list(len(stmt.params), func(int) { p.token(IDENTIFIER) })
+	p.token(RIGHT_PAREN)
+	p.block(len(stmt.body), func(i int) { p.stmt(stmt.body[i]) })
+}
+
+// hasLeadingComments reports whether there are comments before the next token.
+func (p *printer) hasLeadingComments() bool {
+	for _, trivia := range p.leaves[p.cursor].leading {
+		if trivia.kind == COMMENT {
+			return true
+		}
+	}
+	return false
+}
+
+// leading prints the comments before the next token, along with an empty line
+// if there was at least one before it in the source.
+func (p *printer) leading() {
+	if p.measuring || p.led == p.cursor {
+		return
+	}
+	p.led = p.cursor
+
+	// Leading trivia always starts a line: any trivia on the line of the
+	// previous token trails that token instead
+	leaf := p.leaves[p.cursor]
+	newlines := 1
+	for i, trivia := range leaf.leading {
+		switch trivia.kind {
+		case NEWLINE:
+			newlines++
+		case COMMENT:
+			if newlines > 1 {
+				p.blankLine()
+			}
+			p.comment(trivia.text, true, newlineAfter(leaf.leading, i))
+			newlines = 0
+		}
+	}
+
+	switch leaf.token.kind {
+	case EOF, RIGHT_BRACE:
+	default:
+		if newlines > 1 && p.atLineStart {
+			p.blankLine()
+		}
+	}
+}
+
+// list prints n comma-separated items. If they don't fit on the current line
+// they are put one per line, indented.
+func (p *printer) list(n int, each func(i int)) {
+	flat := func() {
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.token(COMMA)
+				p.space = true
+			}
+			each(i)
+		}
+	}
+	// The +1 is for the closing parenthesis
+	if n == 0 || p.measuring || p.col+p.measure(flat)+1 <= maxWidth {
+		flat()
+		return
+	}
+
+	p.indent++
+	for i := 0; i < n; i++ {
+		p.newline()
+		each(i)
+		if i < n-1 {
+			p.token(COMMA)
+		}
+	}
+	p.indent--
+	p.newline()
+}
+
+// measure returns the width of what print would print on a single line,
+// without printing it.
+func (p *printer) measure(print func()) int {
+	saved := *p
+	p.measuring = true
+	p.atLineStart = false
+	p.breakLine = false
+	p.col = 0
+	p.space = false
+
+	print()
+	width := p.col
+
+	*p = saved
+	return width
+}
+
+// newline ends the current line, unless nothing has been written on it.
+func (p *printer) newline() {
+	p.breakLine = false
+	p.space = false
+	if p.atLineStart {
+		return
+	}
+	p.out.WriteByte('\n')
+	p.atLineStart = true
+	p.col = 0
+}
+
+// node prints a statement, without ending its last line.
+func (p *printer) node(stmt Stmt) {
+	switch s := stmt.(type) {
+	case *BlockStmt:
+		if synthesized(s) {
+			// The desugared form of a for loop with an initializer
+			p.forStmt(s.stmts[0], s.stmts[1].(*WhileStmt))
+			break
+		}
+		p.block(len(s.stmts), func(i int) { p.stmt(s.stmts[i]) })
+	case *ClassStmt:
+		p.token(CLASS)
+		p.space = true
+		p.token(IDENTIFIER)
+		if s.superclass != nil {
+			p.operator(LESS)
+			p.token(IDENTIFIER)
+		}
+		p.block(len(s.methods), func(i int) { p.stmt(s.methods[i]) })
+	case *ExpressionStmt:
+		p.expr(s.expr)
+		p.token(SEMICOLON)
+	case *FunctionStmt:
+		p.function(s)
+	case *IfStmt:
+		p.token(IF)
+		p.space = true
+		p.token(LEFT_PAREN)
+		p.expr(s.condition)
+		p.token(RIGHT_PAREN)
+		p.body(s.thenBranch)
+		if s.elseBranch != nil {
+			// "} else" unless a comment comes between them
+			_, ok := s.thenBranch.(*BlockStmt)
+			if ok && !synthesized(s.thenBranch) && !p.breakLine && !p.hasLeadingComments() {
+				p.space = true
+			} else {
+				p.newline()
+				p.continued = false
+			}
+			// An 'if' here continues an else-if chain, so stays on the line
+			p.token(ELSE)
+			p.space = true
+			p.node(s.elseBranch)
+		}
+	case *PrintStmt:
+		p.token(PRINT)
+		p.space = true
+		p.expr(s.expr)
+		p.token(SEMICOLON)
+	case *ReturnStmt:
+		p.token(RETURN)
+		if s.value != nil {
+			p.space = true
+			p.expr(s.value)
+		}
+		p.token(SEMICOLON)
+	case *VarStmt:
+		p.token(VAR)
+		p.space = true
+		p.token(IDENTIFIER)
+		if s.initializer != nil {
+			p.operator(EQUAL)
+			p.expr(s.initializer)
+		}
+		p.token(SEMICOLON)
+	case *WhileStmt:
+		if s.keyword.kind == FOR {
+			p.forStmt(nil, s)
+			break
+		}
+		p.token(WHILE)
+		p.space = true
+		p.token(LEFT_PAREN)
+		p.expr(s.condition)
+		p.token(RIGHT_PAREN)
+		p.body(s.body)
+	default:
+		panic(fmt.Sprintf("format: unexpected statement type %T", s))
+	}
+}
+
+// operator prints a binary operator with a space on either side.
+func (p *printer) operator(kind TokenKind) {
+	p.space = true
+	p.token(kind)
+	p.space = true
+}
+
+// stmt prints a statement on lines of its own.
+func (p *printer) stmt(stmt Stmt) {
+	p.leading()
+	p.node(stmt)
+	p.newline()
+	p.continued = false
+}
+
+// token prints the next token, which must be of the given kind, with the
+// comments around it.
+func (p *printer) token(kind TokenKind) {
+	leaf := p.leaves[p.cursor]
+	if leaf.token.kind != kind {
+		panic(fmt.Sprintf("format: expected %s, found %s", kind, leaf.token.kind))
+	}
+
+	p.leading()
+	p.write(string(leaf.token.lexeme))
+	p.cursor++
+	if p.measuring {
+		return
+	}
+
+	// Trailing comments are on the same line as the token
+	for i, trivia := range leaf.trailing {
+		if trivia.kind == COMMENT {
+			p.comment(trivia.text, false, newlineAfter(leaf.trailing, i))
+		}
+	}
+}
+
+// write writes s, preceded by indentation at the start of a line or else by
+// a pending space.
+func (p *printer) write(s string) {
+	if p.breakLine && !p.measuring {
+		p.newline()
+		p.continued = true
+	}
+	if p.atLineStart {
+		n := p.indent
+		if p.continued {
+			n++
+		}
+		if !p.measuring {
+			p.out.WriteString(strings.Repeat(indentString, n))
+		}
+		p.col = n * len(indentString)
+		p.atLineStart = false
+	} else if p.space {
+		if !p.measuring {
+			p.out.WriteByte(' ')
+		}
+		p.col++
+	}
+	p.space = false
+
+	if !p.measuring {
+		p.out.WriteString(s)
+	}
+	p.col += displayWidth(s)
+}
+
+// newlineAfter reports whether the comment trivia[i] ends its line.
+func newlineAfter(trivia []Trivia, i int) bool {
+	if strings.HasPrefix(trivia[i].text, "//") {
+		return true
+	}
+	for _, t := range trivia[i+1:] {
+		switch t.kind {
+		case NEWLINE:
+			return true
+		case COMMENT:
+			return false
+		}
+	}
+	return false
+}
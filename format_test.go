@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "spacing",
+			src:  "var   x=1+2*-3 ;print(x,x)  ;",
+			want: "var x = 1 + 2 * -3;\nprint (x, x);\n",
+		},
+		{
+			name: "for loop",
+			src:  "for(var i=0;i<3;i=i+1)print i;for(;;){}",
+			want: "for (var i = 0; i < 3; i = i + 1) print i;\nfor (;;) {}\n",
+		},
+		{
+			name: "empty block with comment after brace",
+			src:  "{ // c\n}",
+			want: "{ // c\n}\n",
+		},
+		{
+			name: "empty class with comment after brace",
+			src:  "class C { // body\n}",
+			want: "class C { // body\n}\n",
+		},
+		{
+			name: "comment before else",
+			src:  "if (a) { print 1; } // c\nelse { print 2; }",
+			want: "if (a) {\n  print 1;\n} // c\nelse {\n  print 2;\n}\n",
+		},
+		{
+			name: "comment line before else",
+			src:  "if (a) { print 1; }\n// c\nelse { print 2; }",
+			want: "if (a) {\n  print 1;\n}\n// c\nelse {\n  print 2;\n}\n",
+		},
+		{
+			name: "dangling else",
+			src:  "if (a) if (b) print 1; else print 2;",
+			want: "if (a)\n  if (b) print 1;\n  else print 2;\n",
+		},
+		{
+			name: "if-else as a loop body",
+			src:  "while (a) if (b) print 1; else print 2;\nfor (;;) if (b) print 1;",
+			want: "while (a)\n  if (b) print 1;\n  else print 2;\nfor (;;) if (b) print 1;\n",
+		},
+		{
+			name: "else if",
+			src:  "if (a) { print 1; } else if (b) { print 2; } else print 3;",
+			want: "if (a) {\n  print 1;\n} else if (b) {\n  print 2;\n} else print 3;\n",
+		},
+		{
+			name: "blank lines and comments",
+			src:  "// a\n\n\n\nprint 1; /* b */\nprint 2 + // c\n3;\n// d",
+			want: "// a\n\nprint 1; /* b */\nprint 2 + // c\n  3;\n// d\n",
+		},
+		{
+			name: "wrapped arguments",
+			src:  "print f(aaaaaaaaaaaaaaaaaaaa, bbbbbbbbbbbbbbbbbbbbbbbb, cccccccccccccccccccccc, dddddddd);",
+			want: "print f(\n  aaaaaaaaaaaaaaaaaaaa,\n  bbbbbbbbbbbbbbbbbbbbbbbb,\n  cccccccccccccccccccccc,\n  dddddddd\n);\n",
+		},
+		{
+			name: "wrapped binary expression",
+			src:  "var total = firstValue * secondValue + thirdValueThatIsLong * fourthValueThatIsLong - fifth;",
+			want: "var total = firstValue * secondValue +\n  thirdValueThatIsLong * fourthValueThatIsLong - fifth;\n",
+		},
+		{
+			name: "wrapped logical expression",
+			src:  "if (aaaaaaaaaaaaaaaaaaaa and bbbbbbbbbbbbbbbbbbbbbbbbbb or ccccccccccccccccccccccccc and ddd) print 1;",
+			want: "if (aaaaaaaaaaaaaaaaaaaa and bbbbbbbbbbbbbbbbbbbbbbbbbb or\n  ccccccccccccccccccccccccc and ddd) print 1;\n",
+		},
+		{
+			name: "interpolation",
+			src:  "print \"a ${ x+1 } b\";",
+			want: "print \"a ${x + 1} b\";\n",
+		},
+	}
+
+	format := func(name, src string) string {
+		file := NewFileSet().AddFile(name, -1, len(src))
+		got, errs := Format(file, strings.NewReader(src))
+		if errs.HasErrors() {
+			t.Fatalf("Format(%q) errors: %v", src, errs)
+		}
+		return string(got)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := format(test.name, test.src)
+			if got != test.want {
+				t.Errorf("Format(%q) = %q, want %q", test.src, got, test.want)
+			}
+			if again := format(test.name, got); again != got {
+				t.Errorf("Format is not idempotent: %q became %q", got, again)
+			}
+		})
+	}
+}
@@ -1 +1,3 @@
-module "glox"
+module glox
+
+go 1.21
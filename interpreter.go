@@ -0,0 +1,485 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// http://www.craftinginterpreters.com/evaluating-expressions.html
+
+type RuntimeError struct {
+	token   Token
+	end     Token // optional last token of the offending span, e.g. a call's ')'
+	code    string
+	message string
+}
+
+func (e RuntimeError) Diagnostic() *Diagnostic {
+	end := e.end
+	if !end.pos.IsValid() {
+		end = e.token
+	}
+	return &Diagnostic{
+		Severity: Error,
+		Code:     e.code,
+		Pos:      e.token.pos,
+		End:      end.end,
+		Message:  e.message,
+	}
+}
+
+func (e RuntimeError) Error() string {
+	return e.message
+}
+
+// maxCallDepth is the number of nested calls past which a script is stopped
+// with a stack overflow error, well before the Go stack runs out.
+const maxCallDepth = 10000
+
+type Interpreter struct {
+	callDepth   int // calls currently running
+	environment *Environment
+	globals     *Environment
+	locals      map[Expr]int // scope distance of each resolved local variable reference
+}
+
+func NewInterpreter() *Interpreter {
+	globals := NewEnvironment(nil)
+	for _, native := range natives {
+		globals.define(native.name, native)
+	}
+	return &Interpreter{environment: globals, globals: globals, locals: make(map[Expr]int)}
+}
+
+func (i *Interpreter) checkNumberOperand(op Token, operand interface{}) interface{} {
+	switch operand.(type) {
+	case IntLiteral, FloatLiteral:
+		return operand
+	}
+	panic(RuntimeError{token: op, code: ErrOperandType, message: "Operand must be a number."})
+}
+
+// checkNumberOperands returns lhs and rhs converted to a common numeric type:
+// IntLiteral if both are integers, otherwise FloatLiteral.
+func (i *Interpreter) checkNumberOperands(op Token, lhs, rhs interface{}) (interface{}, interface{}) {
+	if l, r, ok := promote(lhs, rhs); ok {
+		return l, r
+	}
+	panic(RuntimeError{token: op, code: ErrOperandType, message: "Operands must be numbers."})
+}
+
+// arithmetic applies the +, - or * operator op to two operands of the same
+// numeric type. Integer results that don't fit in 64 bits are a runtime error
+// rather than wrapping around.
+func (i *Interpreter) arithmetic(op Token, lhs, rhs interface{}) interface{} {
+	if l, ok := lhs.(IntLiteral); ok {
+		r := rhs.(IntLiteral)
+		var result IntLiteral
+		var overflow bool
+		switch op.kind {
+		case MINUS:
+			result = l - r
+			overflow = (r < 0) != (result > l)
+		case PLUS:
+			result = l + r
+			overflow = (r > 0) != (result > l)
+		case STAR:
+			result = l * r
+			overflow = l != 0 && (result/l != r || l == -1 && r == math.MinInt64)
+		}
+		if overflow {
+			panic(RuntimeError{token: op, code: ErrIntegerOverflow, message: "Integer overflow."})
+		}
+		return result
+	}
+
+	l, r := lhs.(FloatLiteral), rhs.(FloatLiteral)
+	switch op.kind {
+	case MINUS:
+		return l - r
+	case PLUS:
+		return l + r
+	}
+	return l * r
+}
+
+func (i *Interpreter) evaluate(expr Expr) interface{} {
+	return AcceptExpr[interface{}](expr, i)
+}
+
+func (i *Interpreter) execute(stmt Stmt) {
+	AcceptStmt[struct{}](stmt, i)
+}
+
+func (i *Interpreter) executeBlock(stmts []Stmt, environment *Environment) {
+	previous := i.environment
+	defer func() { i.environment = previous }()
+
+	i.environment = environment
+	for _, stmt := range stmts {
+		i.execute(stmt)
+	}
+}
+
+// Interpret executes stmts, stopping at the first runtime error, which it
+// returns as a *Diagnostic.
+func (i *Interpreter) Interpret(stmts []Stmt) (err error) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case RuntimeError:
+			err = r.Diagnostic()
+		case Return:
+			// A top-level return ends the script
+		default:
+			panic(r)
+		}
+	}()
+	for _, stmt := range stmts {
+		i.execute(stmt)
+	}
+	return nil
+}
+
+func (i *Interpreter) lookUpVariable(name Token, expr Expr) interface{} {
+	if distance, ok := i.locals[expr]; ok {
+		return i.environment.getAt(distance, string(name.lexeme))
+	}
+	return i.globals.get(name)
+}
+
+func (i *Interpreter) resolve(expr Expr, depth int) {
+	i.locals[expr] = depth
+}
+
+func (i *Interpreter) visitAssignExpr(expr *AssignExpr) interface{} {
+	value := i.evaluate(expr.value)
+	if distance, ok := i.locals[expr]; ok {
+		i.environment.assignAt(distance, expr.name, value)
+	} else {
+		i.globals.assign(expr.name, value)
+	}
+	return value
+}
+
+func (i *Interpreter) visitBadExpr(expr *BadExpr) interface{} {
+	// Programs with syntax errors are never run
+	panic("glox: cannot evaluate BadExpr")
+}
+
+func (i *Interpreter) visitBinaryExpr(expr *BinaryExpr) interface{} {
+	lhs := i.evaluate(expr.lhs)
+	rhs := i.evaluate(expr.rhs)
+
+	switch expr.op.kind {
+	case COMMA:
+		return rhs
+	case BANG_EQUAL:
+		return BoolLiteral(!isEqual(lhs, rhs))
+	case EQUAL_EQUAL:
+		return BoolLiteral(isEqual(lhs, rhs))
+	case GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		l, r := i.checkNumberOperands(expr.op, lhs, rhs)
+		if l, ok := l.(IntLiteral); ok {
+			return compare(expr.op.kind, l, r.(IntLiteral))
+		}
+		return compare(expr.op.kind, l.(FloatLiteral), r.(FloatLiteral))
+	case MINUS, STAR:
+		l, r := i.checkNumberOperands(expr.op, lhs, rhs)
+		return i.arithmetic(expr.op, l, r)
+	case PLUS:
+		if l, r, ok := promote(lhs, rhs); ok {
+			return i.arithmetic(expr.op, l, r)
+		}
+		if l, ok := lhs.(StringLiteral); ok {
+			if r, ok := rhs.(StringLiteral); ok {
+				return l + r
+			}
+		}
+		panic(RuntimeError{token: expr.op, code: ErrOperandType, message: "Operands must be two numbers or two strings."})
+	case SLASH:
+		// Division is always done in floating point, so 7 / 2 is 3.5
+		l, r := i.checkNumberOperands(expr.op, lhs, rhs)
+		return toFloat(l) / toFloat(r)
+	}
+	panic(RuntimeError{token: expr.op, code: ErrOperandType, message: "Unknown binary operator."})
+}
+
+func (i *Interpreter) visitBlockStmt(stmt *BlockStmt) struct{} {
+	i.executeBlock(stmt.stmts, NewEnvironment(i.environment))
+	return struct{}{}
+}
+
+func (i *Interpreter) visitCallExpr(expr *CallExpr) interface{} {
+	callee := i.evaluate(expr.callee)
+
+	args := make([]interface{}, 0, len(expr.args))
+	for _, arg := range expr.args {
+		args = append(args, i.evaluate(arg))
+	}
+
+	function, ok := callee.(Callable)
+	if !ok {
+		panic(RuntimeError{token: expr.lparen, end: expr.rparen, code: ErrNotCallable,
+			message: "Can only call functions and classes."})
+	}
+	if len(args) != function.Arity() {
+		panic(RuntimeError{token: expr.lparen, end: expr.rparen, code: ErrArity,
+			message: fmt.Sprintf("Expected %d arguments but got %d.", function.Arity(), len(args))})
+	}
+	if i.callDepth == maxCallDepth {
+		panic(RuntimeError{token: expr.lparen, end: expr.rparen, code: ErrStackOverflow, message: "Stack overflow."})
+	}
+	i.callDepth++
+	defer func() { i.callDepth-- }()
+	return function.Call(i, args)
+}
+
+func (i *Interpreter) visitClassStmt(stmt *ClassStmt) struct{} {
+	var superclass *LoxClass
+	if stmt.superclass != nil {
+		class, ok := i.evaluate(stmt.superclass).(*LoxClass)
+		if !ok {
+			panic(RuntimeError{token: stmt.superclass.name, code: ErrSuperclassNotClass, message: "Superclass must be a class."})
+		}
+		superclass = class
+	}
+
+	i.environment.define(string(stmt.name.lexeme), nil)
+
+	if superclass != nil {
+		i.environment = NewEnvironment(i.environment)
+		i.environment.define("super", superclass)
+	}
+
+	methods := make(map[string]*LoxFunction, len(stmt.methods))
+	for _, method := range stmt.methods {
+		name := string(method.name.lexeme)
+		methods[name] = &LoxFunction{declaration: method, closure: i.environment, isInitializer: name == "init"}
+	}
+	class := &LoxClass{name: string(stmt.name.lexeme), superclass: superclass, methods: methods}
+
+	if superclass != nil {
+		i.environment = i.environment.enclosing
+	}
+
+	i.environment.assign(stmt.name, class)
+	return struct{}{}
+}
+
+func (i *Interpreter) visitConditionalExpr(expr *ConditionalExpr) interface{} {
+	if isTruthy(i.evaluate(expr.condition)) {
+		return i.evaluate(expr.thenBranch)
+	}
+	return i.evaluate(expr.elseBranch)
+}
+
+func (i *Interpreter) visitExpressionStmt(stmt *ExpressionStmt) struct{} {
+	i.evaluate(stmt.expr)
+	return struct{}{}
+}
+
+func (i *Interpreter) visitFunctionStmt(stmt *FunctionStmt) struct{} {
+	function := &LoxFunction{declaration: stmt, closure: i.environment}
+	i.environment.define(string(stmt.name.lexeme), function)
+	return struct{}{}
+}
+
+func (i *Interpreter) visitGetExpr(expr *GetExpr) interface{} {
+	if instance, ok := i.evaluate(expr.object).(*LoxInstance); ok {
+		return instance.get(expr.name)
+	}
+	panic(RuntimeError{token: expr.name, code: ErrNotInstance, message: "Only instances have properties."})
+}
+
+func (i *Interpreter) visitGroupingExpr(expr *GroupingExpr) interface{} {
+	return i.evaluate(expr.expr)
+}
+
+func (i *Interpreter) visitIfStmt(stmt *IfStmt) struct{} {
+	if isTruthy(i.evaluate(stmt.condition)) {
+		i.execute(stmt.thenBranch)
+	} else if stmt.elseBranch != nil {
+		i.execute(stmt.elseBranch)
+	}
+	return struct{}{}
+}
+
+func (i *Interpreter) visitInterpolationExpr(expr *InterpolationExpr) interface{} {
+	var b strings.Builder
+	for _, part := range expr.parts {
+		b.WriteString(stringify(i.evaluate(part)))
+	}
+	return StringLiteral(b.String())
+}
+
+func (i *Interpreter) visitLiteralExpr(expr *LiteralExpr) interface{} {
+	return expr.value
+}
+
+func (i *Interpreter) visitLogicalExpr(expr *LogicalExpr) interface{} {
+	lhs := i.evaluate(expr.lhs)
+	if expr.op.kind == OR {
+		if isTruthy(lhs) {
+			return lhs
+		}
+	} else if !isTruthy(lhs) {
+		return lhs
+	}
+	return i.evaluate(expr.rhs)
+}
+
+func (i *Interpreter) visitPrintStmt(stmt *PrintStmt) struct{} {
+	value := i.evaluate(stmt.expr)
+	fmt.Println(stringify(value))
+	return struct{}{}
+}
+
+func (i *Interpreter) visitReturnStmt(stmt *ReturnStmt) struct{} {
+	var value interface{}
+	if stmt.value != nil {
+		value = i.evaluate(stmt.value)
+	}
+	panic(Return{value})
+}
+
+func (i *Interpreter) visitSetExpr(expr *SetExpr) interface{} {
+	instance, ok := i.evaluate(expr.object).(*LoxInstance)
+	if !ok {
+		panic(RuntimeError{token: expr.name, code: ErrNotInstance, message: "Only instances have fields."})
+	}
+	value := i.evaluate(expr.value)
+	instance.set(expr.name, value)
+	return value
+}
+
+func (i *Interpreter) visitSuperExpr(expr *SuperExpr) interface{} {
+	distance := i.locals[expr]
+	superclass := i.environment.getAt(distance, "super").(*LoxClass)
+	// "this" is always bound one scope inside the one holding "super"
+	object := i.environment.getAt(distance-1, "this").(*LoxInstance)
+
+	method := superclass.findMethod(string(expr.method.lexeme))
+	if method == nil {
+		panic(RuntimeError{token: expr.method, code: ErrUndefinedProperty, message: "Undefined property '" + string(expr.method.lexeme) + "'."})
+	}
+	return method.bind(object)
+}
+
+func (i *Interpreter) visitThisExpr(expr *ThisExpr) interface{} {
+	return i.lookUpVariable(expr.keyword, expr)
+}
+
+func (i *Interpreter) visitUnaryExpr(expr *UnaryExpr) interface{} {
+	rhs := i.evaluate(expr.rhs)
+
+	switch expr.op.kind {
+	case BANG:
+		return BoolLiteral(!isTruthy(rhs))
+	case MINUS:
+		switch n := i.checkNumberOperand(expr.op, rhs).(type) {
+		case IntLiteral:
+			if n == math.MinInt64 {
+				panic(RuntimeError{token: expr.op, code: ErrIntegerOverflow, message: "Integer overflow."})
+			}
+			return -n
+		case FloatLiteral:
+			return -n
+		}
+	}
+	panic(RuntimeError{token: expr.op, code: ErrOperandType, message: "Unknown unary operator."})
+}
+
+func (i *Interpreter) visitVariableExpr(expr *VariableExpr) interface{} {
+	return i.lookUpVariable(expr.name, expr)
+}
+
+func (i *Interpreter) visitVarStmt(stmt *VarStmt) struct{} {
+	var value interface{}
+	if stmt.initializer != nil {
+		value = i.evaluate(stmt.initializer)
+	}
+	i.environment.define(string(stmt.name.lexeme), value)
+	return struct{}{}
+}
+
+func (i *Interpreter) visitWhileStmt(stmt *WhileStmt) struct{} {
+	for isTruthy(i.evaluate(stmt.condition)) {
+		i.execute(stmt.body)
+	}
+	return struct{}{}
+}
+
+// compare applies the comparison operator op to two numbers of the same type.
+func compare[T IntLiteral | FloatLiteral](op TokenKind, l, r T) BoolLiteral {
+	switch op {
+	case GREATER:
+		return l > r
+	case GREATER_EQUAL:
+		return l >= r
+	case LESS:
+		return l < r
+	}
+	return l <= r
+}
+
+// isEqual reports whether a and b are equal. Numbers compare by value, so
+// 1 == 1.0 even though one is an integer and the other a float.
+func isEqual(a, b interface{}) bool {
+	if l, r, ok := promote(a, b); ok {
+		return l == r
+	}
+	return a == b
+}
+
+// nil and false are falsey, everything else is truthy
+func isTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case BoolLiteral:
+		return bool(v)
+	}
+	return true
+}
+
+// promote converts two numbers to a common type: IntLiteral if both are
+// integers, otherwise FloatLiteral. ok is false if either isn't a number.
+func promote(lhs, rhs interface{}) (l, r interface{}, ok bool) {
+	switch lhs.(type) {
+	case IntLiteral, FloatLiteral:
+	default:
+		return nil, nil, false
+	}
+	switch rhs.(type) {
+	case IntLiteral, FloatLiteral:
+	default:
+		return nil, nil, false
+	}
+
+	li, lok := lhs.(IntLiteral)
+	ri, rok := rhs.(IntLiteral)
+	if lok && rok {
+		return li, ri, true
+	}
+	return toFloat(lhs), toFloat(rhs), true
+}
+
+func stringify(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+	if s, ok := value.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(value)
+}
+
+// toFloat converts a number to a FloatLiteral.
+func toFloat(n interface{}) FloatLiteral {
+	if i, ok := n.(IntLiteral); ok {
+		return FloatLiteral(i)
+	}
+	return n.(FloatLiteral)
+}
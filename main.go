@@ -2,37 +2,188 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
-var hadError = false
+// Exit codes, from sysexits.h
+const (
+	exitDataErr  = 65 // the input has syntax or resolution errors
+	exitSoftware = 70 // the script failed at runtime
+)
+
+// A session holds the state shared by every source compiled in one run of the
+// command: the file set positions refer to and the reporter that prints
+// diagnostics about them.
+type session struct {
+	fset     *FileSet
+	reporter *Reporter
+}
+
+func newSession() *session {
+	fset := NewFileSet()
+	return &session{fset: fset, reporter: NewReporter(fset)}
+}
 
-func run(source []byte, filename string) {
-	tokens := NewScanner(source, filename).ScanAll()
-	if tokens[0].kind == EOF {
-		return
+// addFile adds a file with the given content to the file set, and makes the
+// content available to the reporter.
+func (s *session) addFile(filename string, source []byte) *File {
+	file := s.fset.AddFile(filename, -1, len(source))
+	s.reporter.AddSource(file, source)
+	return file
+}
+
+// compile scans, parses and resolves source, reporting any errors. The
+// statements are only usable if ok is true.
+func (s *session) compile(interpreter *Interpreter, source []byte, filename string, repl bool) (stmts []Stmt, ok bool) {
+	file := s.addFile(filename, source)
+	_, stmts, errs := parseFile(file, bytes.NewReader(source), 0, repl)
+	if !errs.HasErrors() {
+		resolver := NewResolver(interpreter)
+		errs = append(errs, resolver.Resolve(stmts)...)
 	}
 
-	parser := &Parser{current: 0, filename: filename, source: source, tokens: tokens}
-	expr := parser.Parse()
+	errs.Sort()
+	s.reporter.Report(errs.Err())
+	return stmts, !errs.HasErrors()
+}
+
+// run compiles and executes source and returns the exit code it warrants.
+func (s *session) run(interpreter *Interpreter, source []byte, filename string, repl bool) int {
+	stmts, ok := s.compile(interpreter, source, filename, repl)
+	if !ok {
+		return exitDataErr
+	}
 
-	if hadError {
-		return
+	if err := interpreter.Interpret(stmts); err != nil {
+		s.reporter.Report(err)
+		return exitSoftware
 	}
+	return 0
+}
 
-	var p AstPrinter
-	p.Print(expr)
+// checkFiles reports every syntax and resolution error in the given files
+// without running them.
+func checkFiles(paths []string) {
+	s := newSession()
+	status := 0
+	for _, path := range paths {
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			s.reporter.Report(err)
+			status = 1
+			continue
+		}
+		if _, ok := s.compile(NewInterpreter(), bytes, path, false); !ok {
+			status = exitDataErr
+		}
+	}
+	os.Exit(status)
+}
+
+// formatFiles reformats the given files in the canonical style. By default
+// the result is printed; -w writes it back to the file and -d prints a diff
+// instead.
+func formatFiles(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the source file instead of stdout")
+	diff := flags.Bool("d", false, "print a diff instead of the result")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usage()
+	}
+
+	s := newSession()
+	status := 0
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			s.reporter.Report(err)
+			status = 1
+			continue
+		}
+		result, errs := Format(s.addFile(path, source), bytes.NewReader(source))
+		if errs.HasErrors() {
+			s.reporter.Report(errs.Err())
+			status = exitDataErr
+			continue
+		}
+
+		if *write && !bytes.Equal(source, result) {
+			info, err := os.Stat(path)
+			if err == nil {
+				err = ioutil.WriteFile(path, result, info.Mode().Perm())
+			}
+			if err != nil {
+				s.reporter.Report(err)
+				status = 1
+			}
+		}
+		if *diff {
+			os.Stdout.Write(unifiedDiff(path+".orig", path, source, result))
+		}
+		if !*write && !*diff {
+			os.Stdout.Write(result)
+		}
+	}
+	os.Exit(status)
+}
+
+// vetFiles reports likely bugs in the given files. Rules are turned off with
+// -disable, which takes a comma-separated list of codes.
+func vetFiles(args []string) {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	disable := flags.String("disable", "", "comma-separated codes of the rules to skip")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		usage()
+	}
+
+	disabled := make(map[string]bool)
+	for _, code := range strings.Split(*disable, ",") {
+		if code == "" {
+			continue
+		}
+		if _, ok := VetRules[code]; !ok {
+			fmt.Fprintf(os.Stderr, "glox vet: unknown rule %q\n", code)
+			os.Exit(2)
+		}
+		disabled[code] = true
+	}
+
+	s := newSession()
+	status := 0
+	for _, path := range flags.Args() {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			s.reporter.Report(err)
+			status = 1
+			continue
+		}
+		findings := Vet(s.addFile(path, source), bytes.NewReader(source), disabled)
+		s.reporter.Report(findings.Err())
+		if findings.HasErrors() {
+			status = exitDataErr
+		} else if len(findings) > 0 && status == 0 {
+			status = 1
+		}
+	}
+	os.Exit(status)
 }
 
 func runFile(path string) {
 	bytes, _ := ioutil.ReadFile(path)
-	run(bytes, path)
+	os.Exit(newSession().run(NewInterpreter(), bytes, path, false))
 }
 
 func repl() {
+	s := newSession()
+	interpreter := NewInterpreter()
 	reader := bufio.NewReader(os.Stdin)
 	for {
 		fmt.Print(ANSI_BOLD + "glox> " + ANSI_RESET)
@@ -42,8 +193,7 @@ func repl() {
 			break
 		}
 
-		run(bytes, "?")
-		hadError = false
+		s.run(interpreter, bytes, "?", true)
 	}
 }
 
@@ -51,9 +201,27 @@ func main() {
 	args := os.Args[1:]
 	if len(args) == 0 {
 		repl()
+	} else if args[0] == "check" {
+		if len(args) == 1 {
+			usage()
+		}
+		checkFiles(args[1:])
+	} else if args[0] == "fmt" {
+		formatFiles(args[1:])
+	} else if args[0] == "vet" {
+		vetFiles(args[1:])
 	} else if len(args) == 1 {
 		runFile(args[0])
 	} else {
-		fmt.Println("Usage: glox [script]")
+		usage()
 	}
 }
+
+// usage prints how to run the command and exits with an error status.
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: glox [script]")
+	fmt.Fprintln(os.Stderr, "       glox check files...")
+	fmt.Fprintln(os.Stderr, "       glox fmt [-w] [-d] files...")
+	fmt.Fprintln(os.Stderr, "       glox vet [-disable codes] files...")
+	os.Exit(2)
+}
@@ -1,45 +1,179 @@
 package main
 
-type Err int
-
-const (
-	ParseError Err = iota
+import (
+	"fmt"
+	"io"
 )
 
+// maxArgs is the most arguments a call, or parameters a function, may have.
+const maxArgs = 255
+
+// bailout is panicked with to unwind the parser from a syntax error to the
+// enclosing declaration, which recovers and synchronizes.
+type bailout struct{}
+
+// A Parser builds the syntax tree for the tokens read from a Scanner. It
+// pulls them one at a time as it goes, so the scanner never gets more than
+// one token ahead.
 type Parser struct {
-	current  int
-	filename string
-	source   []byte
-	tokens   []Token
+	errors  ErrorList
+	leaves  []*CSTToken // every token with its trivia, in ScanTrivia mode
+	prev    Token       // the last token consumed
+	repl    bool        // allow a trailing expression without ';' and print it
+	scanner *Scanner
+	tok     Token // one token lookahead
+}
+
+func NewParser(scanner *Scanner) *Parser {
+	p := &Parser{scanner: scanner}
+	p.next()
+	return p
+}
+
+// parseFile scans the content of file, read from src, in the given mode and
+// parses it as a program, as a REPL line if repl is set. It returns the
+// parser, whose leaves hold the tokens in ScanTrivia mode, along with the
+// statements and the scanner and parser errors, sorted.
+func parseFile(file *File, src io.Reader, mode Mode, repl bool) (*Parser, []Stmt, ErrorList) {
+	var errs ErrorList
+	var scanner Scanner
+	scanner.Init(file, src, errs.Add, mode)
+
+	parser := NewParser(&scanner)
+	parser.repl = repl
+	stmts, parseErrs := parser.ParseProgram()
+	errs = append(errs, parseErrs...)
+	errs.Sort()
+	return parser, stmts, errs
 }
 
 func (p *Parser) addition() Expr {
-	expr := p.multiplication()
+	expr := p.missingLeftOperand(p.multiplication, PLUS)
 	for p.match(MINUS, PLUS) {
 		op := p.previous()
 		rhs := p.multiplication()
-		expr = BinaryExpr{op: op, lhs: expr, rhs: rhs}
+		expr = &BinaryExpr{op: op, lhs: expr, rhs: rhs}
 	}
 	return expr
 }
 
 func (p *Parser) advance() Token {
 	if !p.isAtEnd() {
-		p.current++
+		p.prev = p.tok
+		p.next()
 	}
 	return p.previous()
 }
 
+func (p *Parser) and() Expr {
+	expr := p.equality()
+	for p.match(AND) {
+		op := p.previous()
+		rhs := p.equality()
+		expr = &LogicalExpr{op: op, lhs: expr, rhs: rhs}
+	}
+	return expr
+}
+
+func (p *Parser) assignment() Expr {
+	expr := p.conditional()
+	if p.match(EQUAL) {
+		equals := p.previous()
+		value := p.assignment()
+
+		switch target := expr.(type) {
+		case *VariableExpr:
+			return &AssignExpr{name: target.name, value: value}
+		case *GetExpr:
+			return &SetExpr{object: target.object, name: target.name, value: value}
+		}
+
+		// Report but don't panic; the parser isn't confused
+		p.err(equals, ErrInvalidAssignTarget, "Invalid assignment target.")
+	}
+	return expr
+}
+
+func (p *Parser) block() []Stmt {
+	stmts := make([]Stmt, 0)
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		if stmt := p.declaration(); stmt != nil {
+			stmts = append(stmts, stmt)
+		}
+	}
+	p.consume(RIGHT_BRACE, "Expected '}' after block.")
+	return stmts
+}
+
+func (p *Parser) call() Expr {
+	expr := p.primary()
+	for {
+		if p.match(LEFT_PAREN) {
+			expr = p.finishCall(expr)
+		} else if p.match(DOT) {
+			name := p.consume(IDENTIFIER, "Expected property name after '.'.")
+			expr = &GetExpr{object: expr, name: name}
+		} else {
+			break
+		}
+	}
+	return expr
+}
+
 func (p *Parser) check(kind TokenKind) bool {
 	return !p.isAtEnd() && p.peek().kind == kind
 }
 
+func (p *Parser) classDeclaration() Stmt {
+	keyword := p.previous()
+	name := p.consume(IDENTIFIER, "Expected class name.")
+
+	var superclass *VariableExpr
+	if p.match(LESS) {
+		p.consume(IDENTIFIER, "Expected superclass name.")
+		superclass = &VariableExpr{p.previous()}
+	}
+
+	p.consume(LEFT_BRACE, "Expected '{' before class body.")
+	methods := make([]*FunctionStmt, 0)
+	for !p.check(RIGHT_BRACE) && !p.isAtEnd() {
+		methods = append(methods, p.function("method"))
+	}
+	rbrace := p.consume(RIGHT_BRACE, "Expected '}' after class body.")
+
+	return &ClassStmt{keyword: keyword, name: name, superclass: superclass, methods: methods, rbrace: rbrace.pos}
+}
+
+// comma parses the C-style comma operator, which evaluates and discards its
+// left operand and yields its right one.
+func (p *Parser) comma() Expr {
+	expr := p.assignment()
+	for p.match(COMMA) {
+		op := p.previous()
+		rhs := p.assignment()
+		expr = &BinaryExpr{op: op, lhs: expr, rhs: rhs}
+	}
+	return expr
+}
+
 func (p *Parser) comparison() Expr {
-	expr := p.addition()
+	expr := p.missingLeftOperand(p.addition, GREATER, GREATER_EQUAL, LESS, LESS_EQUAL)
 	for p.match(GREATER, GREATER_EQUAL, LESS, LESS_EQUAL) {
 		op := p.previous()
 		rhs := p.addition()
-		expr = BinaryExpr{op: op, lhs: expr, rhs: rhs}
+		expr = &BinaryExpr{op: op, lhs: expr, rhs: rhs}
+	}
+	return expr
+}
+
+// conditional parses the right-associative ternary operator cond ? a : b.
+func (p *Parser) conditional() Expr {
+	expr := p.or()
+	if p.match(QUESTION) {
+		thenBranch := p.expression()
+		p.consume(COLON, "Expected ':' after then branch of conditional expression.")
+		elseBranch := p.conditional()
+		expr = &ConditionalExpr{condition: expr, thenBranch: thenBranch, elseBranch: elseBranch}
 	}
 	return expr
 }
@@ -48,45 +182,194 @@ func (p *Parser) consume(kind TokenKind, message string) Token {
 	if p.check(kind) {
 		return p.advance()
 	}
-	panic(p.err(p.peek(), message))
+	panic(p.err(p.peek(), ErrExpectedToken, message))
+}
+
+// declaration parses a single declaration. On a syntax error it recovers at
+// the next statement boundary and returns nil, so that one pass over a file
+// reports every error in it.
+func (p *Parser) declaration() (stmt Stmt) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			p.synchronize()
+			stmt = nil
+		}
+	}()
+
+	if p.match(CLASS) {
+		return p.classDeclaration()
+	}
+	if p.match(FN) {
+		keyword := p.previous()
+		function := p.function("function")
+		function.keyword = keyword
+		return function
+	}
+	if p.match(VAR) {
+		return p.varDeclaration()
+	}
+	return p.statement()
 }
 
 func (p *Parser) equality() Expr {
-	expr := p.comparison()
+	expr := p.missingLeftOperand(p.comparison, BANG_EQUAL, EQUAL_EQUAL)
 	for p.match(BANG_EQUAL, EQUAL_EQUAL) {
 		op := p.previous()
 		rhs := p.comparison()
-		expr = BinaryExpr{op: op, lhs: expr, rhs: rhs}
+		expr = &BinaryExpr{op: op, lhs: expr, rhs: rhs}
 	}
 	return expr
 }
 
-func (p *Parser) err(token Token, message string) Err {
-	reportError(p.filename, token.line, token.col, len(token.lexeme), p.getLine(token),
-		"[parser] "+message)
-	return ParseError
+// err records a syntax error at token and returns a bailout to panic with.
+// Errors at or just after an ILLEGAL token are not recorded: the scanner has
+// already reported the bad input, and anything more would be a follow-on.
+func (p *Parser) err(token Token, code, message string) bailout {
+	if token.kind == ILLEGAL || p.prev.pos.IsValid() && p.prev.kind == ILLEGAL {
+		return bailout{}
+	}
+	p.errors.Add(&Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Pos:      token.pos,
+		End:      token.end,
+		Message:  message,
+	})
+	return bailout{}
 }
 
 func (p *Parser) expression() Expr {
-	return p.equality()
+	return p.comma()
+}
+
+func (p *Parser) expressionStatement() Stmt {
+	expr := p.expression()
+	if p.repl && p.isAtEnd() {
+		return &PrintStmt{expr: expr}
+	}
+	semicolon := p.consume(SEMICOLON, "Expected ';' after expression.")
+	return &ExpressionStmt{expr: expr, semicolon: semicolon.pos}
 }
 
-func (p *Parser) getLine(token Token) string {
-	// Find start of line
-	start, end := 0, len(p.source)
-	for i := token.line - 1; i > 0; i-- {
-		for p.source[start] != '\n' {
-			start++
+func (p *Parser) finishCall(callee Expr) Expr {
+	lparen := p.previous()
+	args := make([]Expr, 0)
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if len(args) >= maxArgs {
+				p.err(p.peek(), ErrTooManyArgs, fmt.Sprintf("Cannot have more than %d arguments.", maxArgs))
+			}
+			// Parse above the comma operator so ',' separates arguments
+			args = append(args, p.assignment())
+			if !p.match(COMMA) {
+				break
+			}
 		}
 	}
-	// Find end of line
-	for j := start; j < end; j++ {
-		if p.source[j] == '\n' {
-			end = j
-			break
+	rparen := p.consume(RIGHT_PAREN, "Expected ')' after arguments.")
+	return &CallExpr{callee: callee, lparen: lparen, args: args, rparen: rparen}
+}
+
+// forStatement desugars a for loop into an equivalent while loop so later
+// passes only see one loop form:
+//
+//	{ initializer; while (condition) { body; increment; } }
+func (p *Parser) forStatement() Stmt {
+	keyword := p.previous()
+	p.consume(LEFT_PAREN, "Expected '(' after 'for'.")
+
+	var initializer Stmt
+	if p.match(SEMICOLON) {
+		initializer = nil
+	} else if p.match(VAR) {
+		initializer = p.varDeclaration()
+	} else {
+		initializer = p.expressionStatement()
+	}
+
+	var condition Expr
+	if !p.check(SEMICOLON) {
+		condition = p.expression()
+	}
+	p.consume(SEMICOLON, "Expected ';' after loop condition.")
+
+	var increment Expr
+	if !p.check(RIGHT_PAREN) {
+		increment = p.expression()
+	}
+	p.consume(RIGHT_PAREN, "Expected ')' after for clauses.")
+
+	body := p.statement()
+
+	if increment != nil {
+		body = &BlockStmt{stmts: []Stmt{body, &ExpressionStmt{expr: increment}}}
+	}
+	if condition == nil {
+		condition = &LiteralExpr{value: BoolLiteral(true)}
+	}
+	body = &WhileStmt{keyword: keyword, condition: condition, body: body}
+	if initializer != nil {
+		body = &BlockStmt{stmts: []Stmt{initializer, body}}
+	}
+	return body
+}
+
+func (p *Parser) function(kind string) *FunctionStmt {
+	name := p.consume(IDENTIFIER, "Expected "+kind+" name.")
+	p.consume(LEFT_PAREN, "Expected '(' after "+kind+" name.")
+	params := make([]Token, 0)
+	if !p.check(RIGHT_PAREN) {
+		for {
+			if len(params) >= maxArgs {
+				p.err(p.peek(), ErrTooManyArgs, fmt.Sprintf("Cannot have more than %d parameters.", maxArgs))
+			}
+			params = append(params, p.consume(IDENTIFIER, "Expected parameter name."))
+			if !p.match(COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(RIGHT_PAREN, "Expected ')' after parameters.")
+
+	p.consume(LEFT_BRACE, "Expected '{' before "+kind+" body.")
+	body := p.block()
+	return &FunctionStmt{name: name, params: params, body: body, rbrace: p.previous().pos}
+}
+
+func (p *Parser) ifStatement() Stmt {
+	keyword := p.previous()
+	p.consume(LEFT_PAREN, "Expected '(' after 'if'.")
+	condition := p.expression()
+	p.consume(RIGHT_PAREN, "Expected ')' after if condition.")
+
+	thenBranch := p.statement()
+	var elseBranch Stmt
+	if p.match(ELSE) {
+		elseBranch = p.statement()
+	}
+	return &IfStmt{keyword: keyword, condition: condition, thenBranch: thenBranch, elseBranch: elseBranch}
+}
+
+// interpolation parses the rest of an interpolated string whose
+// INTERPOLATION_START token has been consumed. See Scanner.scanString for how
+// the string is split into tokens.
+func (p *Parser) interpolation() Expr {
+	var parts []Expr
+	for {
+		part := p.previous()
+		parts = append(parts, &LiteralExpr{token: part, value: part.literal})
+		if part.kind == INTERPOLATION_END {
+			return &InterpolationExpr{parts: parts}
+		}
+
+		parts = append(parts, p.expression())
+		if !p.match(INTERPOLATION_MIDDLE, INTERPOLATION_END) {
+			panic(p.err(p.peek(), ErrExpectedToken, "Expected '}' after interpolated expression."))
 		}
 	}
-	return string(p.source[start:end])
 }
 
 func (p *Parser) isAtEnd() bool {
@@ -103,57 +386,185 @@ func (p *Parser) match(kinds ...TokenKind) bool {
 	return false
 }
 
+// missingLeftOperand is an error production for a binary operator that
+// starts an expression, e.g. "== 2". If the next token is one of kinds, it
+// reports the missing operand, parses the right-hand operand and returns it
+// only as a stand-in so parsing can go on. Otherwise it parses a regular
+// operand.
+func (p *Parser) missingLeftOperand(operand func() Expr, kinds ...TokenKind) Expr {
+	if p.match(kinds...) {
+		op := p.previous()
+		p.err(op, ErrMissingOperand, fmt.Sprintf("binary operator '%s' is missing its left operand", op.lexeme))
+	}
+	return operand()
+}
+
 func (p *Parser) multiplication() Expr {
-	expr := p.unary()
+	expr := p.missingLeftOperand(p.unary, SLASH, STAR)
 	for p.match(SLASH, STAR) {
 		op := p.previous()
 		rhs := p.unary()
-		expr = BinaryExpr{op: op, lhs: expr, rhs: rhs}
+		expr = &BinaryExpr{op: op, lhs: expr, rhs: rhs}
+	}
+	return expr
+}
+
+// next reads the lookahead token from the scanner. Comments are kept by the
+// scanner for tooling but have no meaning here.
+func (p *Parser) next() {
+	for {
+		pos, kind, lit := p.scanner.Scan()
+		if kind != COMMENT {
+			p.tok = Token{kind: kind, lexeme: []byte(lit), literal: p.scanner.Value(), pos: pos, end: pos + Pos(len(lit))}
+			if p.scanner.mode&ScanTrivia != 0 {
+				leading, trailing := p.scanner.Trivia()
+				p.leaves = append(p.leaves, &CSTToken{token: p.tok, leading: leading, trailing: trailing})
+			}
+			return
+		}
+	}
+}
+
+func (p *Parser) or() Expr {
+	expr := p.and()
+	for p.match(OR) {
+		op := p.previous()
+		rhs := p.and()
+		expr = &LogicalExpr{op: op, lhs: expr, rhs: rhs}
 	}
 	return expr
 }
 
-func (p *Parser) Parse() Expr {
+// Parse parses a single expression.
+func (p *Parser) Parse() (expr Expr, errors ErrorList) {
 	defer func() {
 		// See https://github.com/golang/go/wiki/PanicAndRecover
-		if r := recover(); r != ParseError {
-			// panic(r)
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
 		}
+		errors = p.errors
 	}()
-	return p.expression()
+	return p.expression(), nil
+}
+
+// ParseProgram parses a sequence of declarations up to EOF. It returns the
+// statements it could parse along with every syntax error found.
+func (p *Parser) ParseProgram() ([]Stmt, ErrorList) {
+	stmts := make([]Stmt, 0)
+	for !p.isAtEnd() {
+		if stmt := p.declaration(); stmt != nil {
+			stmts = append(stmts, stmt)
+		} else if p.check(RIGHT_BRACE) {
+			// A '}' with no block to close. The declaration may have failed
+			// at it, in which case it has been reported already
+			if n := len(p.errors); n == 0 || p.errors[n-1].Pos != p.tok.pos {
+				p.err(p.tok, ErrUnmatchedBrace, "Unmatched '}'.")
+			}
+			p.advance()
+		}
+	}
+	return stmts, p.errors
 }
 
 func (p *Parser) peek() Token {
-	return p.tokens[p.current]
+	return p.tok
 }
 
 func (p *Parser) previous() Token {
-	return p.tokens[p.current-1]
+	return p.prev
 }
 
 func (p *Parser) primary() Expr {
+	if p.match(ILLEGAL) {
+		// Already reported by the scanner
+		return &BadExpr{from: p.previous().pos, to: p.previous().end}
+	}
 	if p.match(FALSE) {
-		return LiteralExpr{BoolLiteral(false)}
+		return &LiteralExpr{token: p.previous(), value: BoolLiteral(false)}
 	}
 	if p.match(TRUE) {
-		return LiteralExpr{BoolLiteral(true)}
+		return &LiteralExpr{token: p.previous(), value: BoolLiteral(true)}
 	}
 	if p.match(NIL) {
-		return LiteralExpr{nil}
+		return &LiteralExpr{token: p.previous(), value: nil}
 	}
 	if p.match(NUMBER, STRING) {
-		return LiteralExpr{p.previous().literal}
+		return &LiteralExpr{token: p.previous(), value: p.previous().literal}
+	}
+	if p.match(INTERPOLATION_START) {
+		return p.interpolation()
+	}
+	if p.match(SUPER) {
+		keyword := p.previous()
+		p.consume(DOT, "Expected '.' after 'super'.")
+		method := p.consume(IDENTIFIER, "Expected superclass method name.")
+		return &SuperExpr{keyword: keyword, method: method}
+	}
+	if p.match(THIS) {
+		return &ThisExpr{p.previous()}
+	}
+	if p.match(IDENTIFIER) {
+		return &VariableExpr{p.previous()}
 	}
 	if p.match(LEFT_PAREN) {
+		lparen := p.previous()
 		expr := p.expression()
-		p.consume(RIGHT_PAREN, "Expected ')' after expression.")
-		return GroupingExpr{expr}
+		rparen := p.consume(RIGHT_PAREN, "Expected ')' after expression.")
+		return &GroupingExpr{lparen: lparen.pos, expr: expr, rparen: rparen.pos}
 	}
 
-	panic(p.err(p.peek(), "Expected an expression."))
+	panic(p.err(p.peek(), ErrExpectedExpr, "Expected an expression."))
+}
+
+func (p *Parser) printStatement() Stmt {
+	keyword := p.previous()
+	value := p.expression()
+	semicolon := p.consume(SEMICOLON, "Expected ';' after value.")
+	return &PrintStmt{keyword: keyword, expr: value, semicolon: semicolon.pos}
+}
+
+func (p *Parser) returnStatement() Stmt {
+	keyword := p.previous()
+	var value Expr
+	if !p.check(SEMICOLON) {
+		value = p.expression()
+	}
+	semicolon := p.consume(SEMICOLON, "Expected ';' after return value.")
+	return &ReturnStmt{keyword: keyword, value: value, semicolon: semicolon.pos}
+}
+
+func (p *Parser) statement() Stmt {
+	if p.match(FOR) {
+		return p.forStatement()
+	}
+	if p.match(IF) {
+		return p.ifStatement()
+	}
+	if p.match(WHILE) {
+		return p.whileStatement()
+	}
+	if p.match(PRINT) {
+		return p.printStatement()
+	}
+	if p.match(RETURN) {
+		return p.returnStatement()
+	}
+	if p.match(LEFT_BRACE) {
+		lbrace := p.previous()
+		stmts := p.block()
+		return &BlockStmt{lbrace: lbrace.pos, stmts: stmts, rbrace: p.previous().pos}
+	}
+	return p.expressionStatement()
 }
 
 func (p *Parser) synchronize() {
+	// A '}' closes the block the error is in, so it is left for the block to
+	// consume rather than skipped, which would report the block as unclosed
+	if p.check(RIGHT_BRACE) {
+		return
+	}
 	p.advance()
 	for !p.isAtEnd() {
 		if p.previous().kind == SEMICOLON {
@@ -161,14 +572,7 @@ func (p *Parser) synchronize() {
 		}
 
 		switch p.peek().kind {
-		case CLASS:
-		case FN:
-		case VAR:
-		case FOR:
-		case IF:
-		case WHILE:
-		case PRINT:
-		case RETURN:
+		case CLASS, FN, VAR, FOR, IF, WHILE, PRINT, RETURN, RIGHT_BRACE:
 			return
 		}
 		p.advance()
@@ -179,7 +583,29 @@ func (p *Parser) unary() Expr {
 	for p.match(BANG, MINUS) {
 		op := p.previous()
 		rhs := p.unary()
-		return UnaryExpr{op: op, rhs: rhs}
+		return &UnaryExpr{op: op, rhs: rhs}
+	}
+	return p.call()
+}
+
+func (p *Parser) varDeclaration() Stmt {
+	keyword := p.previous()
+	name := p.consume(IDENTIFIER, "Expected variable name.")
+
+	var initializer Expr
+	if p.match(EQUAL) {
+		initializer = p.expression()
 	}
-	return p.primary()
+
+	semicolon := p.consume(SEMICOLON, "Expected ';' after variable declaration.")
+	return &VarStmt{keyword: keyword, name: name, initializer: initializer, semicolon: semicolon.pos}
+}
+
+func (p *Parser) whileStatement() Stmt {
+	keyword := p.previous()
+	p.consume(LEFT_PAREN, "Expected '(' after 'while'.")
+	condition := p.expression()
+	p.consume(RIGHT_PAREN, "Expected ')' after condition.")
+	body := p.statement()
+	return &WhileStmt{keyword: keyword, condition: condition, body: body}
 }
@@ -17,7 +17,7 @@ type Line struct {
 	Filename string // filename, if any
 	Offset   int    // offset, starting at 0
 	Line     int    // line number, starting at 1
-	Length   int    // length of line
+	Length   int    // length of line, including its newline
 }
 
 // IsValid reports whether the position is valid.
@@ -3,6 +3,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -12,6 +15,7 @@ const (
 	COL_NUM_STYLE  = ANSI_RESET + ANSI_FG_CYAN
 	MESSAGE_STYLE  = ANSI_RESET + ANSI_BOLD
 	INFO_STYLE     = ANSI_RESET + ANSI_FG_GREEN + ANSI_BOLD
+	WARNING_STYLE  = ANSI_RESET + ANSI_FG_YELLOW + ANSI_BOLD
 	ERROR_STYLE    = ANSI_RESET + ANSI_FG_RED + ANSI_BOLD
 )
 
@@ -19,6 +23,7 @@ type LogLevel int
 
 const (
 	Info LogLevel = iota
+	Warning
 	Error
 )
 
@@ -29,8 +34,9 @@ type LogConfig struct {
 }
 
 var LogLevelConfig = map[LogLevel]LogConfig{
-	Info:  {level: "info", style: INFO_STYLE, line: "-----------------------------"},
-	Error: {level: "error", style: ERROR_STYLE, line: "^^^^^^^^^^^^^^^^^^^^^^^^^^^^^"},
+	Info:    {level: "info", style: INFO_STYLE, line: "-----------------------------"},
+	Warning: {level: "warning", style: WARNING_STYLE, line: "^^^^^^^^^^^^^^^^^^^^^^^^^^^^^"},
+	Error:   {level: "error", style: ERROR_STYLE, line: "^^^^^^^^^^^^^^^^^^^^^^^^^^^^^"},
 }
 
 //
@@ -47,16 +53,19 @@ var LogLevelConfig = map[LogLevel]LogConfig{
 // 31 |  }
 //    |  - first borrow ends here
 //
-func report(level LogLevel, filename string, line, col, len int, srcLine, message string) {
+func report(level LogLevel, code, filename string, line, col, len int, srcLine, message string, notes []string) {
 	config := LogLevelConfig[level]
 	padding := countDigits(line)
 
 	// Message
 	fmt.Fprintf(os.Stderr, "%s%s", config.style, config.level)
+	if code != "" {
+		fmt.Fprintf(os.Stderr, "[%s]", code)
+	}
 	fmt.Fprintf(os.Stderr, MESSAGE_STYLE+": %s\n", message)
 	fmt.Fprintf(os.Stderr, LINE_NUM_STYLE+" %*s--> ", padding, "")
 	fmt.Fprintf(os.Stderr, FILENAME_STYLE+"%s", filename)
-	fmt.Fprintf(os.Stderr, LINE_NUM_STYLE+":%d"+COL_NUM_STYLE+":%d\n", line, col+1)
+	fmt.Fprintf(os.Stderr, LINE_NUM_STYLE+":%d"+COL_NUM_STYLE+":%d\n", line, utf8.RuneCountInString(srcLine[:col])+1)
 	fmt.Fprintf(os.Stderr, LINE_NUM_STYLE+" %*s | \n", padding, "")
 	fmt.Fprintf(os.Stderr, LINE_NUM_STYLE+" %d | ", line)
 
@@ -65,19 +74,81 @@ func report(level LogLevel, filename string, line, col, len int, srcLine, messag
 	fmt.Fprintf(os.Stderr, "%s%s", config.style, srcLine[col:col+len])
 	fmt.Fprintf(os.Stderr, LINE_STYLE+"%s\n", srcLine[col+len:])
 
-	// Annotation
+	// Annotation, lined up by display width rather than bytes so wide
+	// characters before or inside the span don't throw it off. At least one
+	// caret is shown so empty spans (e.g. EOF) are visible.
+	carets := displayWidth(srcLine[col : col+len])
+	if carets == 0 {
+		carets = 1
+	}
 	fmt.Fprintf(os.Stderr, LINE_NUM_STYLE+" %*s | ", padding, "")
-	fmt.Fprintf(os.Stderr, "%s%*s%.*s", config.style, col, "", len, config.line)
+	fmt.Fprintf(os.Stderr, "%s%s%s", config.style, indent(srcLine[:col]), strings.Repeat(config.line[:1], carets))
 	fmt.Fprintf(os.Stderr, " %s%s"+ANSI_RESET+"\n", config.style, message)
+
+	// Notes
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, LINE_NUM_STYLE+" %*s = "+MESSAGE_STYLE+"note: "+ANSI_RESET+"%s\n", padding, "", note)
+	}
+}
+
+// A Reporter prints diagnostics along with the source lines they point at.
+// It is only used by the command line tool; library code returns
+// diagnostics instead of printing them.
+type Reporter struct {
+	fset    *FileSet
+	sources map[*File][]byte
+}
+
+func NewReporter(fset *FileSet) *Reporter {
+	return &Reporter{fset: fset, sources: make(map[*File][]byte)}
 }
 
-func reportInfo(filename string, line, col, len int, srcLine, message string) {
-	report(Info, filename, line, col, len, srcLine, message)
+// AddSource records the content of file so diagnostics can quote it.
+func (r *Reporter) AddSource(file *File, source []byte) {
+	r.sources[file] = source
 }
 
-func reportError(filename string, line, col, len int, srcLine, message string) {
-	report(Error, filename, line, col, len, srcLine, message)
-	hadError = true
+// Report prints err, which is usually a *Diagnostic or an ErrorList.
+func (r *Reporter) Report(err error) {
+	switch err := err.(type) {
+	case nil:
+	case ErrorList:
+		for _, d := range err {
+			r.reportDiagnostic(d)
+		}
+	case *Diagnostic:
+		r.reportDiagnostic(err)
+	default:
+		fmt.Fprintf(os.Stderr, "%s%s"+MESSAGE_STYLE+": %s"+ANSI_RESET+"\n", ERROR_STYLE, "error", err)
+	}
+}
+
+func (r *Reporter) reportDiagnostic(d *Diagnostic) {
+	file := r.fset.File(d.Pos)
+	if file == nil {
+		fmt.Fprintf(os.Stderr, "%s%s"+ANSI_RESET+"\n", LogLevelConfig[d.Severity].style, d.Error())
+		return
+	}
+
+	// Both lookups binary search the line table built by the Scanner
+	position := file.Position(d.Pos)
+	line := file.Line(d.Pos)
+	srcLine := strings.TrimRight(string(r.sources[file][line.Offset:line.Offset+line.Length]), "\r\n")
+	col := position.Column - 1
+	if col > len(srcLine) {
+		// e.g. EOF after a trailing newline
+		col = len(srcLine)
+	}
+
+	length := 0
+	if d.End > d.Pos {
+		length = int(d.End - d.Pos)
+	}
+	// Spans running past the end of the line are cut off there
+	if col+length > len(srcLine) {
+		length = len(srcLine) - col
+	}
+	report(d.Severity, d.Code, file.Name, position.Line, col, length, srcLine, d.Message, d.Notes)
 }
 
 func countDigits(i int) int {
@@ -102,3 +173,64 @@ func countDigits(i int) int {
 		return 9
 	}
 }
+
+// displayWidth returns the number of terminal columns s occupies.
+func displayWidth(s string) int {
+	width := 0
+	for _, r := range s {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+// indent returns blanks as wide as s, keeping its tabs so that what follows
+// lines up with the text after s.
+func indent(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteString(strings.Repeat(" ", runeWidth(r)))
+		}
+	}
+	return b.String()
+}
+
+// wideRanges holds the East Asian wide and fullwidth characters, and the
+// emoji, that terminals render two columns wide.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x2E80, 0x303E},   // CJK radicals, Kangxi radicals, CJK symbols
+	{0x3041, 0x33FF},   // Hiragana, Katakana, Bopomofo, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK unified ideographs extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F300, 0x1F64F}, // Miscellaneous symbols and pictographs, emoticons
+	{0x1F680, 0x1F6FF}, // Transport and map symbols
+	{0x1F900, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x1FA70, 0x1FAFF}, // Symbols and pictographs extended-A
+	{0x20000, 0x3FFFD}, // CJK unified ideographs extensions B and up
+}
+
+// runeWidth returns the number of terminal columns r occupies: 0 for
+// combining marks and format characters, 2 for wide characters, otherwise 1.
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) {
+		return 0
+	}
+	for _, wide := range wideRanges {
+		if r < wide[0] {
+			break
+		}
+		if r <= wide[1] {
+			return 2
+		}
+	}
+	return 1
+}
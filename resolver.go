@@ -0,0 +1,324 @@
+package main
+
+// http://www.craftinginterpreters.com/resolving-and-binding.html
+
+type FunctionType int
+
+const (
+	NoFunction FunctionType = iota
+	Function
+	Initializer
+	Method
+)
+
+type ClassType int
+
+const (
+	NoClass ClassType = iota
+	Class
+	Subclass
+)
+
+// A Resolver is a static pass run between the Parser and the Interpreter. It
+// binds every local variable reference to the number of scopes between the
+// reference and its declaration, so closures see the variables that were in
+// scope where they were defined.
+type Resolver struct {
+	currentClass    ClassType
+	currentFunction FunctionType
+	errors          ErrorList
+	interpreter     *Interpreter
+	scopes          []map[string]bool // false until a variable's initializer is resolved
+}
+
+func NewResolver(interpreter *Interpreter) *Resolver {
+	return &Resolver{
+		currentClass:    NoClass,
+		currentFunction: NoFunction,
+		interpreter:     interpreter,
+		scopes:          make([]map[string]bool, 0),
+	}
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]bool))
+}
+
+func (r *Resolver) declare(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	scope := r.scopes[len(r.scopes)-1]
+	if _, ok := scope[string(name.lexeme)]; ok {
+		r.err(name, ErrRedeclared, "Variable with this name already declared in this scope.")
+	}
+	scope[string(name.lexeme)] = false
+}
+
+func (r *Resolver) define(name Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.scopes[len(r.scopes)-1][string(name.lexeme)] = true
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *Resolver) err(token Token, code, message string, notes ...string) {
+	r.errors.Add(&Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Pos:      token.pos,
+		End:      token.end,
+		Message:  message,
+		Notes:    notes,
+	})
+}
+
+// Resolve resolves every variable reference in stmts and returns any errors
+// found.
+func (r *Resolver) Resolve(stmts []Stmt) ErrorList {
+	r.resolveStmts(stmts)
+	return r.errors
+}
+
+func (r *Resolver) resolveExpr(expr Expr) {
+	AcceptExpr[struct{}](expr, r)
+}
+
+func (r *Resolver) resolveFunction(function *FunctionStmt, kind FunctionType) {
+	enclosingFunction := r.currentFunction
+	r.currentFunction = kind
+
+	r.beginScope()
+	for _, param := range function.params {
+		r.declare(param)
+		r.define(param)
+	}
+	r.resolveStmts(function.body)
+	r.endScope()
+
+	r.currentFunction = enclosingFunction
+}
+
+func (r *Resolver) resolveLocal(expr Expr, name Token) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if _, ok := r.scopes[i][string(name.lexeme)]; ok {
+			r.interpreter.resolve(expr, len(r.scopes)-1-i)
+			return
+		}
+	}
+	// Not found. Assume it is global.
+}
+
+func (r *Resolver) resolveStmt(stmt Stmt) {
+	AcceptStmt[struct{}](stmt, r)
+}
+
+func (r *Resolver) resolveStmts(stmts []Stmt) {
+	for _, stmt := range stmts {
+		r.resolveStmt(stmt)
+	}
+}
+
+func (r *Resolver) visitAssignExpr(expr *AssignExpr) struct{} {
+	r.resolveExpr(expr.value)
+	r.resolveLocal(expr, expr.name)
+	return struct{}{}
+}
+
+func (r *Resolver) visitBadExpr(expr *BadExpr) struct{} {
+	return struct{}{}
+}
+
+func (r *Resolver) visitBinaryExpr(expr *BinaryExpr) struct{} {
+	r.resolveExpr(expr.lhs)
+	r.resolveExpr(expr.rhs)
+	return struct{}{}
+}
+
+func (r *Resolver) visitBlockStmt(stmt *BlockStmt) struct{} {
+	r.beginScope()
+	r.resolveStmts(stmt.stmts)
+	r.endScope()
+	return struct{}{}
+}
+
+func (r *Resolver) visitCallExpr(expr *CallExpr) struct{} {
+	r.resolveExpr(expr.callee)
+	for _, arg := range expr.args {
+		r.resolveExpr(arg)
+	}
+	return struct{}{}
+}
+
+func (r *Resolver) visitClassStmt(stmt *ClassStmt) struct{} {
+	enclosingClass := r.currentClass
+	r.currentClass = Class
+
+	r.declare(stmt.name)
+	r.define(stmt.name)
+
+	if stmt.superclass != nil {
+		if string(stmt.name.lexeme) == string(stmt.superclass.name.lexeme) {
+			r.err(stmt.superclass.name, ErrInheritFromSelf, "A class cannot inherit from itself.")
+		}
+		r.currentClass = Subclass
+		r.resolveExpr(stmt.superclass)
+
+		r.beginScope()
+		r.scopes[len(r.scopes)-1]["super"] = true
+	}
+
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = true
+	for _, method := range stmt.methods {
+		kind := Method
+		if string(method.name.lexeme) == "init" {
+			kind = Initializer
+		}
+		r.resolveFunction(method, kind)
+	}
+	r.endScope()
+
+	if stmt.superclass != nil {
+		r.endScope()
+	}
+
+	r.currentClass = enclosingClass
+	return struct{}{}
+}
+
+func (r *Resolver) visitConditionalExpr(expr *ConditionalExpr) struct{} {
+	r.resolveExpr(expr.condition)
+	r.resolveExpr(expr.thenBranch)
+	r.resolveExpr(expr.elseBranch)
+	return struct{}{}
+}
+
+func (r *Resolver) visitExpressionStmt(stmt *ExpressionStmt) struct{} {
+	r.resolveExpr(stmt.expr)
+	return struct{}{}
+}
+
+func (r *Resolver) visitFunctionStmt(stmt *FunctionStmt) struct{} {
+	// Define eagerly so the function can refer to itself recursively
+	r.declare(stmt.name)
+	r.define(stmt.name)
+	r.resolveFunction(stmt, Function)
+	return struct{}{}
+}
+
+func (r *Resolver) visitGetExpr(expr *GetExpr) struct{} {
+	r.resolveExpr(expr.object)
+	return struct{}{}
+}
+
+func (r *Resolver) visitGroupingExpr(expr *GroupingExpr) struct{} {
+	r.resolveExpr(expr.expr)
+	return struct{}{}
+}
+
+func (r *Resolver) visitIfStmt(stmt *IfStmt) struct{} {
+	r.resolveExpr(stmt.condition)
+	r.resolveStmt(stmt.thenBranch)
+	if stmt.elseBranch != nil {
+		r.resolveStmt(stmt.elseBranch)
+	}
+	return struct{}{}
+}
+
+func (r *Resolver) visitInterpolationExpr(expr *InterpolationExpr) struct{} {
+	for _, part := range expr.parts {
+		r.resolveExpr(part)
+	}
+	return struct{}{}
+}
+
+func (r *Resolver) visitLiteralExpr(expr *LiteralExpr) struct{} {
+	return struct{}{}
+}
+
+func (r *Resolver) visitLogicalExpr(expr *LogicalExpr) struct{} {
+	r.resolveExpr(expr.lhs)
+	r.resolveExpr(expr.rhs)
+	return struct{}{}
+}
+
+func (r *Resolver) visitPrintStmt(stmt *PrintStmt) struct{} {
+	r.resolveExpr(stmt.expr)
+	return struct{}{}
+}
+
+func (r *Resolver) visitReturnStmt(stmt *ReturnStmt) struct{} {
+	if r.currentFunction == NoFunction {
+		r.err(stmt.keyword, ErrTopLevelReturn, "Cannot return from top-level code.",
+			"return is only allowed inside a function or method")
+	}
+	if stmt.value != nil {
+		if r.currentFunction == Initializer {
+			r.err(stmt.keyword, ErrInitializerReturn, "Cannot return a value from an initializer.",
+				"init always returns the new instance; use a bare 'return;' to exit early")
+		}
+		r.resolveExpr(stmt.value)
+	}
+	return struct{}{}
+}
+
+func (r *Resolver) visitSetExpr(expr *SetExpr) struct{} {
+	r.resolveExpr(expr.value)
+	r.resolveExpr(expr.object)
+	return struct{}{}
+}
+
+func (r *Resolver) visitSuperExpr(expr *SuperExpr) struct{} {
+	switch r.currentClass {
+	case NoClass:
+		r.err(expr.keyword, ErrSuperOutsideClass, "Cannot use 'super' outside of a class.")
+	case Class:
+		r.err(expr.keyword, ErrSuperNoSuperclass, "Cannot use 'super' in a class with no superclass.")
+	}
+	r.resolveLocal(expr, expr.keyword)
+	return struct{}{}
+}
+
+func (r *Resolver) visitThisExpr(expr *ThisExpr) struct{} {
+	if r.currentClass == NoClass {
+		r.err(expr.keyword, ErrThisOutsideClass, "Cannot use 'this' outside of a class.")
+		return struct{}{}
+	}
+	r.resolveLocal(expr, expr.keyword)
+	return struct{}{}
+}
+
+func (r *Resolver) visitUnaryExpr(expr *UnaryExpr) struct{} {
+	r.resolveExpr(expr.rhs)
+	return struct{}{}
+}
+
+func (r *Resolver) visitVariableExpr(expr *VariableExpr) struct{} {
+	if len(r.scopes) > 0 {
+		if defined, ok := r.scopes[len(r.scopes)-1][string(expr.name.lexeme)]; ok && !defined {
+			r.err(expr.name, ErrOwnInitializer, "Cannot read local variable in its own initializer.")
+		}
+	}
+	r.resolveLocal(expr, expr.name)
+	return struct{}{}
+}
+
+func (r *Resolver) visitVarStmt(stmt *VarStmt) struct{} {
+	r.declare(stmt.name)
+	if stmt.initializer != nil {
+		r.resolveExpr(stmt.initializer)
+	}
+	r.define(stmt.name)
+	return struct{}{}
+}
+
+func (r *Resolver) visitWhileStmt(stmt *WhileStmt) struct{} {
+	r.resolveExpr(stmt.condition)
+	r.resolveStmt(stmt.body)
+	return struct{}{}
+}
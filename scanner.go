@@ -1,8 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"math"
 	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
@@ -11,40 +15,119 @@ const NUL = 0
 
 // const NUL = '\000'
 
+const BOM = 0xFEFF // byte order mark, only permitted as the first character
+
+// bufSize is how much source the Scanner reads at a time.
+const bufSize = 4096
+
+// A Mode value is a set of flags (or 0). They control scanner behavior.
+type Mode uint
+
+const (
+	// ScanTrivia attaches whitespace and comments to the tokens around them,
+	// where Trivia returns them, instead of returning comments as COMMENT
+	// tokens and dropping whitespace.
+	ScanTrivia Mode = 1 << iota
+)
+
+// A Trivia is a piece of source text between tokens: a WHITESPACE run, a
+// NEWLINE or a COMMENT.
+type Trivia struct {
+	kind TokenKind
+	pos  Pos
+	text string
+}
+
+// An ErrorHandler may be provided to Scanner.Init. It is called with each
+// error the scanner finds.
+type ErrorHandler func(d *Diagnostic)
+
+// A Scanner tokenizes source read from an io.Reader. Only the text of the
+// token being scanned is kept in memory, so a large source doesn't have to be
+// read in whole first. Its size must still be known up front, as the File
+// positions refer to is created with it. A Scanner must be initialized via
+// Init before use.
 type Scanner struct {
-	current   int
-	start     int
-	line      int // replace w/ pos
-	col       int // replace w/ pos
-	sourceLen int
-	filename  string // replace w/ FileSet?
-	source    []byte
-	tokens    []Token
+	// immutable state
+	errh ErrorHandler
+	file *File
+	mode Mode
+	src  io.Reader
+
+	// scanning state
+	base           int    // file offset of buf[0]
+	buf            []byte // source read but not yet discarded
+	current        int
+	eof            bool  // all of src has been read into buf
+	interpolations []int // brace depth inside each open "${", innermost last
+	start          int
+
+	// last token scanned
+	kind     TokenKind
+	leading  []Trivia // in ScanTrivia mode
+	scanned  bool
+	trailing []Trivia // in ScanTrivia mode
+	value    Literal
+
+	ErrorCount int // number of errors encountered
 }
 
-func NewScanner(source []byte, filename string) *Scanner {
-	return &Scanner{
-		current:   0,
-		start:     0,
-		line:      1,
-		col:       0,
-		filename:  filename,
-		sourceLen: len(source),
-		source:    source,
-		tokens:    make([]Token, 0, 256),
+// Init prepares s to tokenize src, which holds the content of file. Errors
+// are passed to errh if it is not nil. The mode parameter determines how
+// whitespace and comments are handled.
+//
+// src is expected to hold exactly file.Size bytes. If it turns out to hold
+// more or fewer, the mismatch is reported as an error and scanning ends where
+// the shorter of the two does.
+func (s *Scanner) Init(file *File, src io.Reader, errh ErrorHandler, mode Mode) {
+	*s = Scanner{errh: errh, file: file, mode: mode, src: src}
+
+	// Skip a leading byte order mark, unless it's to be kept as trivia
+	if ch, width := s.readRune(0); ch == BOM && mode&ScanTrivia == 0 {
+		s.current = width
 	}
 }
 
+// addNumber adds a NUMBER token for the literal just scanned. A malformed or
+// out of range literal is reported and given the value 0 so parsing can go on.
+func (s *Scanner) addNumber(base int, isFloat bool) {
+	lexeme := string(s.text(s.start, s.current))
+	if msg := checkNumber(lexeme, base); msg != "" {
+		s.err(s.start, s.current-s.start, ErrInvalidNumber, msg)
+		s.addTokenLiteral(NUMBER, IntLiteral(0))
+		return
+	}
+
+	digits := strings.ReplaceAll(lexeme, "_", "")
+	if isFloat {
+		f, err := strconv.ParseFloat(digits, 64)
+		if err != nil {
+			s.err(s.start, s.current-s.start, ErrNumberOverflow, "Float literal overflows float64.")
+		}
+		s.addTokenLiteral(NUMBER, FloatLiteral(f))
+		return
+	}
+
+	if base != 10 {
+		digits = digits[2:]
+	}
+	i, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		s.err(s.start, s.current-s.start, ErrNumberOverflow,
+			fmt.Sprintf("Integer literal overflows int64 (max %d).", int64(math.MaxInt64)))
+		i = 0
+	}
+	s.addTokenLiteral(NUMBER, IntLiteral(i))
+}
+
 func (s *Scanner) addToken(kind TokenKind) {
 	s.addTokenLiteral(kind, nil)
 }
 
 func (s *Scanner) addTokenLiteral(kind TokenKind, literal Literal) {
-	lexeme := s.source[s.start:s.current]
-	col := s.col - len(lexeme)
-	token := Token{kind: kind, lexeme: lexeme, literal: literal, line: s.line, col: col}
-	// s.info(&token)
-	s.tokens = append(s.tokens, token)
+	s.kind = kind
+	s.scanned = true
+	s.value = literal
 }
 
 func (s *Scanner) addTokenFor(ch rune, matched TokenKind, unmatched TokenKind) {
@@ -55,65 +138,89 @@ func (s *Scanner) addTokenFor(ch rune, matched TokenKind, unmatched TokenKind) {
 	}
 }
 
-func (s *Scanner) err(pos, len int, message string) {
-	line, col, src := s.getLineInfo(pos)
-	reportError(s.filename, line, col, len, src, "[scanner] "+message)
-}
-
-func (s *Scanner) getLineInfo(pos int) (line, col int, src string) {
-	line, start, end := 1, 0, s.sourceLen
-	if pos > end {
-		panic(fmt.Sprintf(ANSI_RESET+"pos (%d) out of bounds (0, %d)", pos, end))
+func (s *Scanner) err(offset, len int, code, message string) {
+	if s.errh != nil {
+		s.errh(&Diagnostic{
+			Severity: Error,
+			Code:     code,
+			Pos:      s.file.Pos(offset),
+			End:      s.file.Pos(offset + len),
+			Message:  message,
+		})
 	}
+	s.ErrorCount++
+}
 
-	// Find line number and start of line
-	for i := 0; i < pos; i++ {
-		if s.source[i] == '\n' {
-			line++
-			start = i + 1
+// fill reads from src until buf holds the source up to offset end or src is
+// exhausted. To make room it first discards the text before the current
+// token, which is no longer needed.
+func (s *Scanner) fill(end int) {
+	for !s.eof && s.base+len(s.buf) < end {
+		if len(s.buf) == cap(s.buf) {
+			if n := s.start - s.base; n > 0 {
+				s.buf = s.buf[:copy(s.buf, s.buf[n:])]
+				s.base = s.start
+			}
+			if cap(s.buf)-len(s.buf) < bufSize {
+				buf := make([]byte, len(s.buf), 2*cap(s.buf)+bufSize)
+				copy(buf, s.buf)
+				s.buf = buf
+			}
 		}
-	}
 
-	// Find end of line
-	for j := pos; j < end; j++ {
-		if s.source[j] == '\n' {
-			end = j
-			break
+		n, err := s.src.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		size := s.base + len(s.buf)
+		switch {
+		case size > s.file.Size:
+			// Positions past the end of the file don't exist, so the rest of
+			// src is dropped
+			s.buf = s.buf[:s.file.Size-s.base]
+			s.eof = true
+			s.err(s.file.Size, 0, ErrReadSource,
+				fmt.Sprintf("Source is longer than the file size (%d bytes).", s.file.Size))
+		case err == io.EOF && size < s.file.Size:
+			s.eof = true
+			s.err(size, 0, ErrReadSource,
+				fmt.Sprintf("Source ends after %d bytes, short of the file size (%d bytes).", size, s.file.Size))
+		case err != nil:
+			s.eof = true
+			if err != io.EOF {
+				s.err(size, 0, ErrReadSource, "Error reading source: "+err.Error()+".")
+			}
 		}
 	}
-
-	return line, pos - start, string(s.source[start:end])
-}
-
-func (s *Scanner) info(tok *Token) {
-	line, col, src := s.getLineInfo(s.start)
-	reportInfo(s.filename, line, col, len(tok.lexeme), src, "[scanner] "+tok.kind.String())
 }
 
 func (s *Scanner) isAtEnd() bool {
-	return s.current >= s.sourceLen
+	s.fill(s.current + 1)
+	return s.current >= s.base+len(s.buf)
 }
 
 func (s *Scanner) match(expected rune) bool {
-	if s.isAtEnd() || s.readRune(s.current) != expected {
+	if s.isAtEnd() {
+		return false
+	}
+	ch, width := s.readRune(s.current)
+	if ch != expected {
 		return false
 	}
 
-	s.current++
-	s.col++
+	s.current += width
 	return true
 }
 
+// Next consumes and returns the next rune, reporting invalid UTF-8. An
+// invalid byte is returned as utf8.RuneError and consumed on its own.
 func (s *Scanner) Next() rune {
-	ch := s.readRune(s.current)
-	s.current++
+	ch, width := s.readRune(s.current)
+	if ch == utf8.RuneError && width == 1 {
+		s.err(s.current, 1, ErrInvalidUTF8, fmt.Sprintf("Invalid UTF-8 encoding (byte %#02x).", s.text(s.current, s.current+1)[0]))
+	}
+	s.current += width
 	if ch == '\n' {
-		s.line++
-		s.col = 0
-	} else {
-		s.col++
+		s.file.AddLine(s.current)
 	}
-
 	return ch
 }
 
@@ -121,22 +228,264 @@ func (s *Scanner) Peek() rune {
 	if s.isAtEnd() {
 		return NUL
 	}
-	return s.readRune(s.current)
+	ch, _ := s.readRune(s.current)
+	return ch
 }
 
 func (s *Scanner) PeekNext() rune {
-	if s.current+1 >= s.sourceLen {
+	if s.isAtEnd() {
+		return NUL
+	}
+	_, width := s.readRune(s.current)
+	ch, width := s.readRune(s.current + width)
+	if width == 0 {
 		return NUL
 	}
-	return s.readRune(s.current + 1)
+	return ch
 }
 
-func (s *Scanner) readRune(offset int) rune {
-	ch, _ := utf8.DecodeRune(s.source[offset:])
-	return ch
+// readRune decodes the rune at offset, which must not be before the current
+// token. At the end of the source it returns width 0.
+func (s *Scanner) readRune(offset int) (ch rune, width int) {
+	s.fill(offset + utf8.UTFMax)
+	return utf8.DecodeRune(s.buf[offset-s.base:])
+}
+
+// Scan scans the next token and returns its position, its kind and its
+// source text. The end of the source is indicated by EOF. The values of
+// NUMBER, STRING and INTERPOLATION_* tokens are available from Value, and in
+// ScanTrivia mode the whitespace and comments around the token from Trivia.
+func (s *Scanner) Scan() (pos Pos, kind TokenKind, lit string) {
+	if s.mode&ScanTrivia != 0 {
+		s.leading = s.scanTrivia(false)
+		s.trailing = nil
+	}
+
+	s.scanned = false
+	for !s.scanned {
+		s.start = s.current
+		if s.isAtEnd() {
+			return s.file.Pos(s.current), EOF, ""
+		}
+		s.scanToken()
+	}
+
+	pos, kind, lit = s.file.Pos(s.start), s.kind, string(s.text(s.start, s.current))
+	if s.mode&ScanTrivia != 0 {
+		s.trailing = s.scanTrivia(true)
+	}
+	return pos, kind, lit
 }
 
-func (s *Scanner) Scan() {
+// scanBlockComment scans a /* ... */ comment, which may contain nested block
+// comments. It reports whether the comment was terminated.
+func (s *Scanner) scanBlockComment() bool {
+	depth := 1
+	for depth > 0 && !s.isAtEnd() {
+		switch {
+		case s.Peek() == '/' && s.PeekNext() == '*':
+			s.Next()
+			s.Next()
+			depth++
+		case s.Peek() == '*' && s.PeekNext() == '/':
+			s.Next()
+			s.Next()
+			depth--
+		default:
+			s.Next()
+		}
+	}
+	if depth > 0 {
+		levels := "levels"
+		if depth == 1 {
+			levels = "level"
+		}
+		s.err(s.start, 2, ErrUnterminatedComment, fmt.Sprintf("Unterminated block comment (%d %s still open).", depth, levels))
+		return false
+	}
+	return true
+}
+
+func (s *Scanner) scanComment() {
+	s.scanUntil('\n')
+}
+
+// scanDigits consumes decimal digits and '_' separators.
+func (s *Scanner) scanDigits() {
+	for isDigit(s.Peek()) || s.Peek() == '_' {
+		s.Next()
+	}
+}
+
+// scanEscape scans the escape sequence starting at the next backslash and
+// writes the character it stands for to b. Invalid escapes are reported and
+// write nothing.
+func (s *Scanner) scanEscape(b *strings.Builder) {
+	offset := s.current
+	s.Next()
+	if s.isAtEnd() {
+		// Reported as an unterminated string
+		return
+	}
+
+	ch := s.Next()
+	switch ch {
+	case '"':
+		b.WriteByte('"')
+	case '$':
+		b.WriteByte('$')
+	case '\\':
+		b.WriteByte('\\')
+	case 'n':
+		b.WriteByte('\n')
+	case 'r':
+		b.WriteByte('\r')
+	case 't':
+		b.WriteByte('\t')
+	case 'u':
+		if r, ok := s.scanUnicodeEscape(offset); ok {
+			b.WriteRune(r)
+		}
+	default:
+		s.err(offset, s.current-offset, ErrInvalidEscape, fmt.Sprintf("Invalid escape sequence '\\%c'.", ch))
+	}
+}
+
+func (s *Scanner) scanIdentifier() {
+	for isAlphaOrDigit(s.Peek()) {
+		s.Next()
+	}
+
+	lexeme := string(s.text(s.start, s.current))
+	kind, ok := Keywords[lexeme]
+	if !ok {
+		kind = IDENTIFIER
+	}
+	s.addToken(kind)
+}
+
+// scanNumber scans an integer or float literal. Integers may have a 0x, 0o or
+// 0b prefix, and any number may use '_' to separate digits. A fraction or an
+// exponent makes the literal a float.
+func (s *Scanner) scanNumber() {
+	// The first digit has already been consumed
+	base := 10
+	if s.text(s.start, s.current)[0] == '0' {
+		switch s.Peek() {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+	}
+
+	if base != 10 {
+		// Consume the prefix and anything that could be a digit, so that
+		// e.g. 0b12 is reported as one bad literal rather than 0b1 then 2
+		s.Next()
+		for isAlphaOrDigit(s.Peek()) {
+			s.Next()
+		}
+		s.addNumber(base, false)
+		return
+	}
+
+	s.scanDigits()
+	isFloat := false
+
+	// Look for a fractional part
+	if s.Peek() == '.' && isDigit(s.PeekNext()) {
+		// Consume the "."
+		s.Next()
+		s.scanDigits()
+		isFloat = true
+	}
+
+	// Look for an exponent, which must have at least one digit
+	if ch := s.Peek(); ch == 'e' || ch == 'E' {
+		next := s.PeekNext()
+		if next == '+' || next == '-' {
+			if ch, _ := s.readRune(s.current + 2); isDigit(ch) {
+				s.Next()
+				s.Next()
+				s.scanDigits()
+				isFloat = true
+			}
+		} else if isDigit(next) {
+			s.Next()
+			s.scanDigits()
+			isFloat = true
+		}
+	}
+
+	s.addNumber(base, isFloat)
+}
+
+// scanRawString scans a backtick-quoted string, which may span lines and
+// has no escape sequences. As in Go, carriage returns are dropped from it.
+func (s *Scanner) scanRawString() {
+	s.scanUntil('`')
+	if s.isAtEnd() {
+		s.err(s.start, s.current-s.start, ErrUnterminatedString, "Unterminated raw string.")
+		s.addToken(ILLEGAL)
+		return
+	}
+
+	// Consume the closing backtick and return string excluding backticks
+	s.Next()
+	str := bytes.ReplaceAll(s.text(s.start+1, s.current-1), []byte("\r"), nil)
+	s.addTokenLiteral(STRING, StringLiteral(str))
+}
+
+// scanString scans a double-quoted string, interpreting its escape sequences.
+// It is also called to resume a string after the "}" closing an embedded
+// "${expression}", in which case resumed is true. A string without embedded
+// expressions is a single STRING token; otherwise its parts become
+// INTERPOLATION_START, INTERPOLATION_MIDDLE and INTERPOLATION_END tokens
+// around the expressions' tokens, e.g. "a ${x} b ${y} c" is scanned as
+//
+//	INTERPOLATION_START("a ") IDENTIFIER(x) INTERPOLATION_MIDDLE(" b ")
+//	IDENTIFIER(y) INTERPOLATION_END(" c")
+func (s *Scanner) scanString(resumed bool) {
+	var b strings.Builder
+	for s.Peek() != '"' && !s.isAtEnd() {
+		switch {
+		case s.Peek() == '\\':
+			s.scanEscape(&b)
+		case s.Peek() == '$' && s.PeekNext() == '{':
+			s.Next()
+			s.Next()
+			s.interpolations = append(s.interpolations, 0)
+			kind := INTERPOLATION_START
+			if resumed {
+				kind = INTERPOLATION_MIDDLE
+			}
+			s.addTokenLiteral(kind, StringLiteral(b.String()))
+			return
+		default:
+			offset := s.current
+			s.Next()
+			b.Write(s.text(offset, s.current))
+		}
+	}
+	if s.isAtEnd() {
+		s.err(s.start, s.current-s.start, ErrUnterminatedString, "Unterminated string.")
+		s.addToken(ILLEGAL)
+		return
+	}
+
+	// Consume the closing double-quote
+	s.Next()
+	kind := STRING
+	if resumed {
+		kind = INTERPOLATION_END
+	}
+	s.addTokenLiteral(kind, StringLiteral(b.String()))
+}
+
+func (s *Scanner) scanToken() {
 	ch := s.Next()
 	switch ch {
 	case '(':
@@ -144,9 +493,23 @@ func (s *Scanner) Scan() {
 	case ')':
 		s.addToken(RIGHT_PAREN)
 	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1]++
+		}
 		s.addToken(LEFT_BRACE)
 	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1] == 0 {
+				// The "}" closing a "${" resumes the string it was in
+				s.interpolations = s.interpolations[:n-1]
+				s.scanString(true)
+				break
+			}
+			s.interpolations[n-1]--
+		}
 		s.addToken(RIGHT_BRACE)
+	case ':':
+		s.addToken(COLON)
 	case ',':
 		s.addToken(COMMA)
 	case '.':
@@ -155,6 +518,8 @@ func (s *Scanner) Scan() {
 		s.addToken(MINUS)
 	case '+':
 		s.addToken(PLUS)
+	case '?':
+		s.addToken(QUESTION)
 	case ';':
 		s.addToken(SEMICOLON)
 	case '*':
@@ -170,6 +535,11 @@ func (s *Scanner) Scan() {
 	case '/':
 		if s.match('/') {
 			s.scanComment()
+			s.addToken(COMMENT)
+		} else if s.match('*') {
+			if s.scanBlockComment() {
+				s.addToken(COMMENT)
+			}
 		} else {
 			s.addToken(SLASH)
 		}
@@ -179,95 +549,188 @@ func (s *Scanner) Scan() {
 	case '\n':
 		break
 	case '"':
-		s.scanString()
+		s.scanString(false)
+	case '`':
+		s.scanRawString()
 	default:
 		if isDigit(ch) {
 			s.scanNumber()
 		} else if isAlpha(ch) {
 			s.scanIdentifier()
+		} else if ch == utf8.RuneError && s.current-s.start == 1 {
+			// Invalid UTF-8, already reported by Next
+			s.addToken(ILLEGAL)
 		} else {
-			s.err(s.start, 1, "Unexpected character: '"+string(ch)+"'")
-			// exit
+			s.err(s.start, s.current-s.start, ErrUnexpectedChar, "Unexpected character: '"+string(ch)+"'")
+			s.addToken(ILLEGAL)
 		}
 	}
 }
 
-func (s *Scanner) ScanAll() []Token {
+// scanTrivia scans whitespace and comments. For the trivia trailing a token
+// it stops after the first newline, since what follows leads the next token.
+func (s *Scanner) scanTrivia(trailing bool) []Trivia {
+	var trivia []Trivia
 	for !s.isAtEnd() {
 		s.start = s.current
-		s.Scan()
-	}
+		var kind TokenKind
+		switch ch := s.Peek(); {
+		case ch == BOM && s.current == 0:
+			s.Next()
+			kind = WHITESPACE
+		case ch == ' ' || ch == '\r' || ch == '\t':
+			for ch := s.Peek(); ch == ' ' || ch == '\r' || ch == '\t'; ch = s.Peek() {
+				s.Next()
+			}
+			kind = WHITESPACE
+		case ch == '\n':
+			s.Next()
+			kind = NEWLINE
+		case ch == '/' && s.PeekNext() == '/':
+			s.Next()
+			s.Next()
+			s.scanComment()
+			kind = COMMENT
+		case ch == '/' && s.PeekNext() == '*':
+			s.Next()
+			s.Next()
+			s.scanBlockComment()
+			kind = COMMENT
+		default:
+			return trivia
+		}
 
-	s.tokens = append(s.tokens, Token{kind: EOF, line: s.line})
-	return s.tokens
+		trivia = append(trivia, Trivia{kind: kind, pos: s.file.Pos(s.start), text: string(s.text(s.start, s.current))})
+		if trailing && kind == NEWLINE {
+			break
+		}
+	}
+	return trivia
 }
 
-func (s *Scanner) scanComment() {
-	s.scanUntil('\n')
-	s.addToken(COMMENT)
-}
+// scanUnicodeEscape scans the {X...} part of a \u{X...} escape, which holds
+// 1 to 6 hex digits naming a Unicode code point. offset is where the escape's
+// backslash is.
+func (s *Scanner) scanUnicodeEscape(offset int) (rune, bool) {
+	if !s.match('{') {
+		s.err(offset, s.current-offset, ErrInvalidEscape, "Expected '{' after '\\u'.")
+		return 0, false
+	}
 
-func (s *Scanner) scanIdentifier() {
-	for isAlphaOrDigit(s.Peek()) {
+	start := s.current
+	for isHexDigit(s.Peek()) {
 		s.Next()
 	}
+	digits := string(s.text(start, s.current))
+	if !s.match('}') {
+		s.err(offset, s.current-offset, ErrInvalidEscape, "Expected '}' to close '\\u{' escape.")
+		return 0, false
+	}
 
-	lexeme := string(s.source[s.start:s.current])
-	kind, ok := Keywords[lexeme]
-	if !ok {
-		kind = IDENTIFIER
+	value, err := strconv.ParseUint(digits, 16, 32)
+	switch {
+	case len(digits) == 0:
+		s.err(offset, s.current-offset, ErrInvalidEscape, "Unicode escape has no digits.")
+	case len(digits) > 6 || err != nil || value > unicode.MaxRune:
+		s.err(offset, s.current-offset, ErrInvalidEscape, "Unicode escape is out of range (max \\u{10FFFF}).")
+	case 0xD800 <= value && value <= 0xDFFF:
+		s.err(offset, s.current-offset, ErrInvalidEscape, "Unicode escape is a surrogate half, which isn't a valid code point.")
+	default:
+		return rune(value), true
 	}
-	s.addToken(kind)
+	return 0, false
 }
 
-func (s *Scanner) scanNumber() {
-	for isDigit(s.Peek()) {
+func (s *Scanner) scanUntil(until rune) {
+	for s.Peek() != until && !s.isAtEnd() {
 		s.Next()
 	}
+}
 
-	// Look for a fractional part
-	if s.Peek() == '.' && isDigit(s.PeekNext()) {
-		// Consume the "."
-		s.Next()
+// text returns the source between offsets from and to, which must not be
+// before the current token. It is only valid until the next read from src.
+func (s *Scanner) text(from, to int) []byte {
+	return s.buf[from-s.base : to-s.base]
+}
 
-		for isDigit(s.Peek()) {
-			s.Next()
-		}
-	}
+// Trivia returns the whitespace and comments before and after the last token
+// scanned in ScanTrivia mode. The trailing trivia runs up to and including
+// the end of the token's line; the rest leads the next token. The trivia at
+// the end of the source leads EOF.
+func (s *Scanner) Trivia() (leading, trailing []Trivia) {
+	return s.leading, s.trailing
+}
 
-	// TODO error handling
-	float, _ := strconv.ParseFloat(string(s.source[s.start:s.current]), 64)
-	s.addTokenLiteral(NUMBER, FloatLiteral(float))
+// Value returns the value of the last token scanned, which is nil for tokens
+// other than NUMBER, STRING and INTERPOLATION_*.
+func (s *Scanner) Value() Literal {
+	return s.value
 }
 
-func (s *Scanner) scanString() {
-	s.scanUntil('"')
-	if s.isAtEnd() {
-		// -1 to remove trailing newline / EOF
-		s.err(s.start, s.current-s.start-1, "Unterminated string.")
-		return
+// checkNumber returns a description of what is wrong with the number literal
+// lexeme, or "" if it is well formed. Like Go, '_' may only appear between
+// two digits or between the base prefix and a digit.
+func checkNumber(lexeme string, base int) string {
+	name := map[int]string{2: "binary", 8: "octal", 10: "decimal", 16: "hexadecimal"}[base]
+	isBaseDigit := isDigit
+	if base == 16 {
+		isBaseDigit = isHexDigit
 	}
 
-	// Consume the closing double-quote and return string excluding quotes
-	s.Next()
-	str := s.source[s.start+1 : s.current-1]
-	s.addTokenLiteral(STRING, StringLiteral(str))
+	start := 0
+	if base != 10 {
+		start = 2
+		if strings.Trim(lexeme[start:], "_") == "" {
+			return fmt.Sprintf("%s literal has no digits.", strings.ToUpper(name[:1])+name[1:])
+		}
+		for _, ch := range lexeme[start:] {
+			if ch != '_' && (!isBaseDigit(ch) || digitValue(ch) >= base) {
+				return fmt.Sprintf("Invalid digit %q in %s literal.", ch, name)
+			}
+		}
+	}
+
+	for i := start; i < len(lexeme); i++ {
+		if lexeme[i] != '_' {
+			continue
+		}
+		prefix := i == start && base != 10
+		if !prefix && !isBaseDigit(rune(lexeme[i-1])) || i+1 == len(lexeme) || !isBaseDigit(rune(lexeme[i+1])) {
+			return "'_' must separate successive digits."
+		}
+	}
+	return ""
 }
 
-func (s *Scanner) scanUntil(until rune) {
-	for s.Peek() != until && !s.isAtEnd() {
-		s.Next()
+// digitValue returns the value of the (hexadecimal) digit ch.
+func digitValue(ch rune) int {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return int(ch - '0')
+	case 'a' <= ch && ch <= 'f':
+		return int(ch - 'a' + 10)
+	case 'A' <= ch && ch <= 'F':
+		return int(ch - 'A' + 10)
 	}
+	return 16
 }
 
 func isAlpha(ch rune) bool {
 	return unicode.IsLetter(ch) || ch == '_'
 }
 
+// isAlphaOrDigit reports whether ch may continue an identifier. Like Go, any
+// Unicode letter or digit is allowed.
 func isAlphaOrDigit(ch rune) bool {
-	return isAlpha(ch) || isDigit(ch)
+	return isAlpha(ch) || unicode.IsDigit(ch)
 }
 
+// isDigit reports whether ch is an ASCII digit, the only ones allowed in
+// number literals.
 func isDigit(ch rune) bool {
-	return unicode.IsDigit(ch)
+	return '0' <= ch && ch <= '9'
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
 }
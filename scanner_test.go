@@ -0,0 +1,50 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScannerSizeMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		size int
+		want []TokenKind
+	}{
+		{name: "exact", src: "print 1;", size: 8, want: []TokenKind{PRINT, NUMBER, SEMICOLON, EOF}},
+		{name: "source longer", src: "print 1; print 2;", size: 8, want: []TokenKind{PRINT, NUMBER, SEMICOLON, EOF}},
+		{name: "source shorter", src: "print 1;", size: 18, want: []TokenKind{PRINT, NUMBER, SEMICOLON, EOF}},
+		{name: "source much longer", src: strings.Repeat("x ", 3*bufSize), size: 4, want: []TokenKind{IDENTIFIER, IDENTIFIER, EOF}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var errs ErrorList
+			var s Scanner
+			file := NewFileSet().AddFile(test.name, -1, test.size)
+			s.Init(file, strings.NewReader(test.src), errs.Add, 0)
+
+			var got []TokenKind
+			for {
+				_, kind, _ := s.Scan()
+				got = append(got, kind)
+				if kind == EOF || len(got) > len(test.want) {
+					break
+				}
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("tokens = %v, want %v", got, test.want)
+			}
+
+			if len(test.src) == test.size {
+				if len(errs) > 0 {
+					t.Errorf("errors = %v, want none", errs)
+				}
+			} else if len(errs) != 1 || errs[0].Code != ErrReadSource {
+				t.Errorf("errors = %v, want one %s", errs, ErrReadSource)
+			}
+		})
+	}
+}
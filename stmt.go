@@ -0,0 +1,201 @@
+package main
+
+import "fmt"
+
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Desugared statements, such as the blocks a for loop expands into, have
+// NoPos for the tokens that don't appear in the source. Their Pos and End
+// fall back to those of their children.
+
+type BlockStmt struct {
+	lbrace Pos
+	stmts  []Stmt
+	rbrace Pos
+}
+
+type ClassStmt struct {
+	keyword    Token
+	name       Token
+	superclass *VariableExpr
+	methods    []*FunctionStmt
+	rbrace     Pos
+}
+
+type ExpressionStmt struct {
+	expr      Expr
+	semicolon Pos
+}
+
+type FunctionStmt struct {
+	keyword Token // zero for methods, which have no 'fn'
+	name    Token
+	params  []Token
+	body    []Stmt
+	rbrace  Pos
+}
+
+type IfStmt struct {
+	keyword    Token
+	condition  Expr
+	thenBranch Stmt
+	elseBranch Stmt
+}
+
+type PrintStmt struct {
+	keyword   Token
+	expr      Expr
+	semicolon Pos
+}
+
+type ReturnStmt struct {
+	keyword   Token
+	value     Expr
+	semicolon Pos
+}
+
+type VarStmt struct {
+	keyword     Token
+	name        Token
+	initializer Expr
+	semicolon   Pos
+}
+
+// WhileStmt is also the desugared form of a for loop, in which case keyword
+// is the 'for' token.
+type WhileStmt struct {
+	keyword   Token
+	condition Expr
+	body      Stmt
+}
+
+func (*BlockStmt) stmtNode()      {}
+func (*ClassStmt) stmtNode()      {}
+func (*ExpressionStmt) stmtNode() {}
+func (*FunctionStmt) stmtNode()   {}
+func (*IfStmt) stmtNode()         {}
+func (*PrintStmt) stmtNode()      {}
+func (*ReturnStmt) stmtNode()     {}
+func (*VarStmt) stmtNode()        {}
+func (*WhileStmt) stmtNode()      {}
+
+func (stmt *BlockStmt) Pos() Pos {
+	if stmt.lbrace.IsValid() {
+		return stmt.lbrace
+	}
+	// A desugared block's statements needn't be in source order
+	pos := NoPos
+	for _, s := range stmt.stmts {
+		if p := s.Pos(); p.IsValid() && (pos == NoPos || p < pos) {
+			pos = p
+		}
+	}
+	return pos
+}
+
+func (stmt *ClassStmt) Pos() Pos      { return stmt.keyword.pos }
+func (stmt *ExpressionStmt) Pos() Pos { return stmt.expr.Pos() }
+
+func (stmt *FunctionStmt) Pos() Pos {
+	if stmt.keyword.pos.IsValid() {
+		return stmt.keyword.pos
+	}
+	return stmt.name.pos
+}
+
+func (stmt *IfStmt) Pos() Pos { return stmt.keyword.pos }
+
+func (stmt *PrintStmt) Pos() Pos {
+	if stmt.keyword.pos.IsValid() {
+		return stmt.keyword.pos
+	}
+	return stmt.expr.Pos()
+}
+
+func (stmt *ReturnStmt) Pos() Pos { return stmt.keyword.pos }
+func (stmt *VarStmt) Pos() Pos    { return stmt.keyword.pos }
+func (stmt *WhileStmt) Pos() Pos  { return stmt.keyword.pos }
+
+func (stmt *BlockStmt) End() Pos {
+	if stmt.rbrace.IsValid() {
+		return stmt.rbrace + 1
+	}
+	end := NoPos
+	for _, s := range stmt.stmts {
+		if e := s.End(); e > end {
+			end = e
+		}
+	}
+	return end
+}
+
+func (stmt *ClassStmt) End() Pos { return stmt.rbrace + 1 }
+
+func (stmt *ExpressionStmt) End() Pos {
+	if stmt.semicolon.IsValid() {
+		return stmt.semicolon + 1
+	}
+	return stmt.expr.End()
+}
+
+func (stmt *FunctionStmt) End() Pos { return stmt.rbrace + 1 }
+
+func (stmt *IfStmt) End() Pos {
+	if stmt.elseBranch != nil {
+		return stmt.elseBranch.End()
+	}
+	return stmt.thenBranch.End()
+}
+
+func (stmt *PrintStmt) End() Pos {
+	if stmt.semicolon.IsValid() {
+		return stmt.semicolon + 1
+	}
+	return stmt.expr.End()
+}
+
+func (stmt *ReturnStmt) End() Pos { return stmt.semicolon + 1 }
+func (stmt *VarStmt) End() Pos    { return stmt.semicolon + 1 }
+func (stmt *WhileStmt) End() Pos  { return stmt.body.End() }
+
+// A StmtVisitor is a pass over statements producing a result of type R.
+type StmtVisitor[R any] interface {
+	visitBlockStmt(stmt *BlockStmt) R
+	visitClassStmt(stmt *ClassStmt) R
+	visitExpressionStmt(stmt *ExpressionStmt) R
+	visitFunctionStmt(stmt *FunctionStmt) R
+	visitIfStmt(stmt *IfStmt) R
+	visitPrintStmt(stmt *PrintStmt) R
+	visitReturnStmt(stmt *ReturnStmt) R
+	visitVarStmt(stmt *VarStmt) R
+	visitWhileStmt(stmt *WhileStmt) R
+}
+
+// AcceptStmt dispatches stmt to the matching method of v and returns its
+// result.
+func AcceptStmt[R any](stmt Stmt, v StmtVisitor[R]) R {
+	switch stmt := stmt.(type) {
+	case *BlockStmt:
+		return v.visitBlockStmt(stmt)
+	case *ClassStmt:
+		return v.visitClassStmt(stmt)
+	case *ExpressionStmt:
+		return v.visitExpressionStmt(stmt)
+	case *FunctionStmt:
+		return v.visitFunctionStmt(stmt)
+	case *IfStmt:
+		return v.visitIfStmt(stmt)
+	case *PrintStmt:
+		return v.visitPrintStmt(stmt)
+	case *ReturnStmt:
+		return v.visitReturnStmt(stmt)
+	case *VarStmt:
+		return v.visitVarStmt(stmt)
+	case *WhileStmt:
+		return v.visitWhileStmt(stmt)
+	}
+	panic(fmt.Sprintf("unexpected statement type %T", stmt))
+}
@@ -21,10 +21,8 @@ type Token struct {
 	kind    TokenKind
 	lexeme  []byte
 	literal Literal
-	line    int
-	col     int
-	// pos     start
-	// pos     end
+	pos     Pos // position of the first character of the token
+	end     Pos // position immediately after the token
 }
 
 func (t Token) String() string {
@@ -37,16 +35,20 @@ const (
 	ILLEGAL TokenKind = iota
 	EOF
 	COMMENT
+	NEWLINE    // only as Trivia
+	WHITESPACE // only as Trivia
 
 	// Single-character tokens
 	LEFT_PAREN  // (
 	RIGHT_PAREN // )
 	LEFT_BRACE  // {
 	RIGHT_BRACE // {
+	COLON       // :
 	COMMA       // ,
 	DOT         // .
 	MINUS       // -
 	PLUS        // +
+	QUESTION    // ?
 	SEMICOLON   // ;
 	SLASH       // /
 	STAR        // *
@@ -64,6 +66,9 @@ const (
 	// Literals
 	IDENTIFIER
 	STRING
+	INTERPOLATION_START  // "...${  parts of an interpolated string,
+	INTERPOLATION_MIDDLE // }...${  see Scanner.scanString
+	INTERPOLATION_END    // }..."
 	NUMBER
 
 	// Keywords
@@ -86,47 +91,54 @@ const (
 )
 
 var TokenKinds = map[TokenKind]string{
-	ILLEGAL:       "ILLEGAL",
-	EOF:           "EOF",
-	COMMENT:       "COMMENT",
-	LEFT_PAREN:    "LEFT_PAREN",
-	RIGHT_PAREN:   "RIGHT_PAREN",
-	LEFT_BRACE:    "LEFT_BRACE",
-	RIGHT_BRACE:   "RIGHT_BRACE",
-	COMMA:         "COMMA",
-	DOT:           "DOT",
-	MINUS:         "MINUS",
-	PLUS:          "PLUS",
-	SEMICOLON:     "SEMICOLON",
-	SLASH:         "SLASH",
-	STAR:          "STAR",
-	BANG:          "BANG",
-	BANG_EQUAL:    "BANG_EQUAL",
-	EQUAL:         "EQUAL",
-	EQUAL_EQUAL:   "EQUAL_EQUAL",
-	GREATER:       "GREATER",
-	GREATER_EQUAL: "GREATER_EQUAL",
-	LESS:          "LESS",
-	LESS_EQUAL:    "LESS_EQUAL",
-	IDENTIFIER:    "IDENTIFIER",
-	STRING:        "STRING",
-	NUMBER:        "NUMBER",
-	AND:           "AND",
-	CLASS:         "CLASS",
-	ELSE:          "ELSE",
-	FALSE:         "FALSE",
-	FN:            "FN",
-	FOR:           "FOR",
-	IF:            "IF",
-	NIL:           "NIL",
-	OR:            "OR",
-	PRINT:         "PRINT",
-	RETURN:        "RETURN",
-	SUPER:         "SUPER",
-	THIS:          "THIS",
-	TRUE:          "TRUE",
-	VAR:           "VAR",
-	WHILE:         "WHILE",
+	ILLEGAL:              "ILLEGAL",
+	EOF:                  "EOF",
+	COMMENT:              "COMMENT",
+	NEWLINE:              "NEWLINE",
+	WHITESPACE:           "WHITESPACE",
+	LEFT_PAREN:           "LEFT_PAREN",
+	RIGHT_PAREN:          "RIGHT_PAREN",
+	LEFT_BRACE:           "LEFT_BRACE",
+	RIGHT_BRACE:          "RIGHT_BRACE",
+	COLON:                "COLON",
+	COMMA:                "COMMA",
+	DOT:                  "DOT",
+	MINUS:                "MINUS",
+	PLUS:                 "PLUS",
+	QUESTION:             "QUESTION",
+	SEMICOLON:            "SEMICOLON",
+	SLASH:                "SLASH",
+	STAR:                 "STAR",
+	BANG:                 "BANG",
+	BANG_EQUAL:           "BANG_EQUAL",
+	EQUAL:                "EQUAL",
+	EQUAL_EQUAL:          "EQUAL_EQUAL",
+	GREATER:              "GREATER",
+	GREATER_EQUAL:        "GREATER_EQUAL",
+	LESS:                 "LESS",
+	LESS_EQUAL:           "LESS_EQUAL",
+	IDENTIFIER:           "IDENTIFIER",
+	STRING:               "STRING",
+	INTERPOLATION_START:  "INTERPOLATION_START",
+	INTERPOLATION_MIDDLE: "INTERPOLATION_MIDDLE",
+	INTERPOLATION_END:    "INTERPOLATION_END",
+	NUMBER:               "NUMBER",
+	AND:                  "AND",
+	CLASS:                "CLASS",
+	ELSE:                 "ELSE",
+	FALSE:                "FALSE",
+	FN:                   "FN",
+	FOR:                  "FOR",
+	IF:                   "IF",
+	NIL:                  "NIL",
+	OR:                   "OR",
+	PRINT:                "PRINT",
+	RETURN:               "RETURN",
+	SUPER:                "SUPER",
+	THIS:                 "THIS",
+	TRUE:                 "TRUE",
+	VAR:                  "VAR",
+	WHILE:                "WHILE",
 }
 
 func (k TokenKind) String() string {
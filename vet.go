@@ -0,0 +1,407 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// VetRules maps the code of each rule Vet checks to a short description.
+var VetRules = map[string]string{
+	WarnUnusedVariable:    "local variable declared but never read",
+	WarnUnreachableCode:   "statement after a return",
+	WarnSelfComparison:    "value compared with itself",
+	WarnConstantCondition: "if or while condition that is a constant",
+	WarnShadowedVariable:  "declaration hiding a variable of an outer scope",
+	WarnMixedComparison:   "equality comparison of literals of different types",
+	WarnDivisionByZero:    "division by a literal zero",
+}
+
+// vetIgnore is the comment that turns off rules for a line. It is followed by
+// the codes of the rules to turn off, or by nothing to turn them all off:
+//
+//	print x == x; // vet:ignore W0503
+//
+// A comment on a line of its own applies to the line after it ends. Codes of
+// rules that don't exist are reported as errors, as they are for -disable.
+const vetIgnore = "vet:ignore"
+
+// Vet checks the content of file, read from src, for likely bugs, skipping
+// the rules whose codes are in disabled. Syntax and resolution errors are
+// returned instead of findings, since a program that doesn't compile can't be
+// vetted.
+func Vet(file *File, src io.Reader, disabled map[string]bool) ErrorList {
+	parser, stmts, errs := parseFile(file, src, ScanTrivia, false)
+	if !errs.HasErrors() {
+		errs = append(errs, NewResolver(NewInterpreter()).Resolve(stmts)...)
+	}
+	if errs.HasErrors() {
+		errs.Sort()
+		return errs
+	}
+
+	v := &vetter{disabled: disabled, file: file, ignored: make(map[int]map[string]bool)}
+	v.collectIgnores(parser.leaves)
+	// The globals' scope is never ended: other scripts run in the same
+	// interpreter could use them
+	v.beginScope()
+	v.stmts(stmts)
+
+	v.findings.Sort()
+	return v.findings
+}
+
+// A vetter walks a syntax tree, keeping track of the variables in scope, and
+// records the findings of Vet.
+type vetter struct {
+	disabled map[string]bool
+	file     *File
+	findings ErrorList
+	ignored  map[int]map[string]bool // codes turned off by line, all of them if empty
+	scopes   [][]*vetLocal           // the first holds the globals
+}
+
+// A vetLocal is a variable declared in a scope.
+type vetLocal struct {
+	name  Token
+	param bool
+	used  bool
+}
+
+func (v *vetter) beginScope() {
+	v.scopes = append(v.scopes, nil)
+}
+
+func (v *vetter) binary(expr *BinaryExpr) {
+	switch expr.op.kind {
+	case EQUAL_EQUAL, BANG_EQUAL, GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+		// The result isn't fixed: NaN isn't equal to itself, nor are two
+		// methods bound from the same property
+		if sameExpr(expr.lhs, expr.rhs) {
+			v.warn(WarnSelfComparison, expr.Pos(), expr.End(), "Comparison of a value with itself.")
+			return
+		}
+		if expr.op.kind != EQUAL_EQUAL && expr.op.kind != BANG_EQUAL {
+			return
+		}
+		lhs, rhs := literalType(expr.lhs), literalType(expr.rhs)
+		if lhs != "" && rhs != "" && lhs != rhs {
+			v.warn(WarnMixedComparison, expr.Pos(), expr.End(),
+				fmt.Sprintf("Comparison of %s and %s is always %t.", lhs, rhs, expr.op.kind == BANG_EQUAL),
+				"values of different types are never equal")
+		}
+	case SLASH:
+		if isZero(expr.rhs) {
+			v.warn(WarnDivisionByZero, expr.Pos(), expr.End(), "Division by zero.",
+				"the result is infinite, or NaN if the dividend is also zero")
+		}
+	}
+}
+
+// collectIgnores records the lines turned off by vet:ignore comments.
+func (v *vetter) collectIgnores(leaves []*CSTToken) {
+	add := func(trivia Trivia, ownLine bool) {
+		text := strings.TrimPrefix(trivia.text, "//")
+		if strings.HasPrefix(text, "/*") {
+			text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		}
+		text = strings.TrimSpace(text)
+		if !strings.HasPrefix(text, vetIgnore) {
+			return
+		}
+
+		fields := strings.FieldsFunc(text[len(vetIgnore):], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+		})
+		codes := make(map[string]bool)
+		for _, code := range fields {
+			if _, ok := VetRules[code]; !ok {
+				v.findings.Add(&Diagnostic{
+					Severity: Error,
+					Code:     ErrUnknownRule,
+					Pos:      trivia.pos,
+					End:      trivia.pos + Pos(len(trivia.text)),
+					Message:  fmt.Sprintf("Unknown rule '%s' in %s comment.", code, vetIgnore),
+				})
+				continue
+			}
+			codes[code] = true
+		}
+		if len(fields) > 0 && len(codes) == 0 {
+			// Only unknown codes, which mustn't turn off every rule
+			return
+		}
+		v.ignore(v.file.Position(trivia.pos).Line, codes)
+		if ownLine {
+			end := v.file.Position(trivia.pos + Pos(len(trivia.text))).Line
+			v.ignore(end+1, codes)
+		}
+	}
+
+	for _, leaf := range leaves {
+		for _, trivia := range leaf.leading {
+			if trivia.kind == COMMENT {
+				add(trivia, true)
+			}
+		}
+		for _, trivia := range leaf.trailing {
+			if trivia.kind == COMMENT {
+				add(trivia, false)
+			}
+		}
+	}
+}
+
+// condition checks the condition of an if or while statement.
+func (v *vetter) condition(condition Expr) {
+	if value, ok := constantValue(condition); ok {
+		v.warn(WarnConstantCondition, condition.Pos(), condition.End(),
+			fmt.Sprintf("Condition is always %t.", isTruthy(value)))
+	}
+	v.expr(condition)
+}
+
+// declare adds a variable to the innermost scope, warning if it hides one of
+// an outer scope.
+func (v *vetter) declare(name Token, param bool) {
+	if !param {
+		if outer := v.lookup(string(name.lexeme), len(v.scopes)-1); outer != nil {
+			v.warn(WarnShadowedVariable, name.pos, name.end,
+				fmt.Sprintf("Declaration of '%s' shadows a variable in an outer scope.", name.lexeme),
+				fmt.Sprintf("'%s' is declared on line %d", name.lexeme, v.file.Position(outer.name.pos).Line))
+		}
+	}
+	scope := &v.scopes[len(v.scopes)-1]
+	*scope = append(*scope, &vetLocal{name: name, param: param})
+}
+
+// endScope ends the innermost scope, warning about its unused locals.
+func (v *vetter) endScope() {
+	for _, local := range v.scopes[len(v.scopes)-1] {
+		if !local.used && !local.param {
+			v.warn(WarnUnusedVariable, local.name.pos, local.name.end,
+				fmt.Sprintf("'%s' is declared but never used.", local.name.lexeme))
+		}
+	}
+	v.scopes = v.scopes[:len(v.scopes)-1]
+}
+
+func (v *vetter) expr(expr Expr) {
+	Inspect(expr, func(n Node) bool {
+		switch n := n.(type) {
+		case *BinaryExpr:
+			v.binary(n)
+		case *VariableExpr:
+			if local := v.lookup(string(n.name.lexeme), len(v.scopes)); local != nil {
+				local.used = true
+			}
+		}
+		return true
+	})
+}
+
+func (v *vetter) function(function *FunctionStmt) {
+	v.beginScope()
+	for _, param := range function.params {
+		v.declare(param, true)
+	}
+	v.stmts(function.body)
+	v.endScope()
+}
+
+// ignore turns off the rules with the given codes, or all of them if codes
+// is empty, for a line.
+func (v *vetter) ignore(line int, codes map[string]bool) {
+	ignored, ok := v.ignored[line]
+	if !ok || len(codes) == 0 {
+		v.ignored[line] = codes
+		return
+	}
+	if len(ignored) > 0 {
+		for code := range codes {
+			ignored[code] = true
+		}
+	}
+}
+
+// lookup returns the innermost variable called name in the scopes below the
+// given depth, or nil if there is none.
+func (v *vetter) lookup(name string, depth int) *vetLocal {
+	for i := depth - 1; i >= 0; i-- {
+		scope := v.scopes[i]
+		for j := len(scope) - 1; j >= 0; j-- {
+			if string(scope[j].name.lexeme) == name {
+				return scope[j]
+			}
+		}
+	}
+	return nil
+}
+
+func (v *vetter) stmt(stmt Stmt) {
+	switch s := stmt.(type) {
+	case *BlockStmt:
+		v.beginScope()
+		v.stmts(s.stmts)
+		v.endScope()
+	case *ClassStmt:
+		if s.superclass != nil {
+			v.expr(s.superclass)
+		}
+		v.declare(s.name, false)
+		for _, method := range s.methods {
+			v.function(method)
+		}
+	case *ExpressionStmt:
+		v.expr(s.expr)
+	case *FunctionStmt:
+		// Declared first so the function can refer to itself recursively
+		v.declare(s.name, false)
+		v.function(s)
+	case *IfStmt:
+		v.condition(s.condition)
+		v.stmt(s.thenBranch)
+		if s.elseBranch != nil {
+			v.stmt(s.elseBranch)
+		}
+	case *PrintStmt:
+		v.expr(s.expr)
+	case *ReturnStmt:
+		if s.value != nil {
+			v.expr(s.value)
+		}
+	case *VarStmt:
+		if s.initializer != nil {
+			v.expr(s.initializer)
+		}
+		v.declare(s.name, false)
+	case *WhileStmt:
+		v.condition(s.condition)
+		v.stmt(s.body)
+	}
+}
+
+func (v *vetter) stmts(stmts []Stmt) {
+	for i, stmt := range stmts {
+		v.stmt(stmt)
+		if _, ok := stmt.(*ReturnStmt); ok && i+1 < len(stmts) {
+			next := stmts[i+1]
+			v.warn(WarnUnreachableCode, next.Pos(), next.End(), "Unreachable code after return.")
+		}
+	}
+}
+
+// warn records a finding, unless its rule is disabled or turned off for the
+// line it starts on.
+func (v *vetter) warn(code string, pos, end Pos, message string, notes ...string) {
+	if v.disabled[code] {
+		return
+	}
+	if codes, ok := v.ignored[v.file.Position(pos).Line]; ok && (len(codes) == 0 || codes[code]) {
+		return
+	}
+	v.findings.Add(&Diagnostic{
+		Severity: Warning,
+		Code:     code,
+		Pos:      pos,
+		End:      end,
+		Message:  message,
+		Notes:    notes,
+	})
+}
+
+// constantValue returns the value of expr if it is a literal, possibly
+// parenthesized or negated with '!'. Literals synthesized by the parser, like
+// the condition of a for loop without one, don't count.
+func constantValue(expr Expr) (value interface{}, ok bool) {
+	switch e := expr.(type) {
+	case *GroupingExpr:
+		return constantValue(e.expr)
+	case *LiteralExpr:
+		return e.value, e.token.pos.IsValid()
+	case *UnaryExpr:
+		if e.op.kind == BANG {
+			if value, ok := constantValue(e.rhs); ok {
+				return BoolLiteral(!isTruthy(value)), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// isZero reports whether expr is a number literal equal to zero.
+func isZero(expr Expr) bool {
+	switch e := expr.(type) {
+	case *GroupingExpr:
+		return isZero(e.expr)
+	case *LiteralExpr:
+		switch value := e.value.(type) {
+		case IntLiteral:
+			return value == 0
+		case FloatLiteral:
+			return value == 0
+		}
+	case *UnaryExpr:
+		return e.op.kind == MINUS && isZero(e.rhs)
+	}
+	return false
+}
+
+// literalType returns the type of the value of expr if it can be told without
+// running it, otherwise "".
+func literalType(expr Expr) string {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		switch e.op.kind {
+		case EQUAL_EQUAL, BANG_EQUAL, GREATER, GREATER_EQUAL, LESS, LESS_EQUAL:
+			return "boolean"
+		}
+	case *GroupingExpr:
+		return literalType(e.expr)
+	case *InterpolationExpr:
+		return "string"
+	case *LiteralExpr:
+		switch e.token.kind {
+		case FALSE, TRUE:
+			return "boolean"
+		case NIL:
+			return "nil"
+		case NUMBER:
+			return "number"
+		case STRING:
+			return "string"
+		}
+	case *UnaryExpr:
+		if e.op.kind == BANG {
+			return "boolean"
+		}
+		if literalType(e.rhs) == "number" {
+			return "number"
+		}
+	}
+	return ""
+}
+
+// sameExpr reports whether a and b are the same variable, property, this or
+// literal.
+func sameExpr(a, b Expr) bool {
+	switch a := a.(type) {
+	case *GetExpr:
+		b, ok := b.(*GetExpr)
+		return ok && bytes.Equal(a.name.lexeme, b.name.lexeme) && sameExpr(a.object, b.object)
+	case *GroupingExpr:
+		b, ok := b.(*GroupingExpr)
+		return ok && sameExpr(a.expr, b.expr)
+	case *LiteralExpr:
+		b, ok := b.(*LiteralExpr)
+		return ok && bytes.Equal(a.token.lexeme, b.token.lexeme)
+	case *ThisExpr:
+		_, ok := b.(*ThisExpr)
+		return ok
+	case *VariableExpr:
+		b, ok := b.(*VariableExpr)
+		return ok && bytes.Equal(a.name.lexeme, b.name.lexeme)
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestVet(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		disabled []string
+		want     []string // codes of the findings, in order
+	}{
+		{name: "clean", src: "var x = 1;\nprint x;\n"},
+		{name: "syntax error", src: "print ;\n", want: []string{ErrExpectedExpr}},
+
+		{name: "W0501", src: "{ var x = 1; }\n", want: []string{WarnUnusedVariable}},
+		{name: "W0501 ignored", src: "{ var x = 1; } // vet:ignore W0501\n"},
+		{name: "W0502", src: "fn f() {\n  return 1;\n  print 2;\n}\n", want: []string{WarnUnreachableCode}},
+		{name: "W0502 ignored", src: "fn f() {\n  return 1;\n  // vet:ignore W0502\n  print 2;\n}\n"},
+		{name: "W0503", src: "var x = 1;\nprint x == x;\n", want: []string{WarnSelfComparison}},
+		{name: "W0503 ignored", src: "var x = 1;\nprint x == x; // vet:ignore W0503\n"},
+		{name: "W0504", src: "while (!nil) print 1;\n", want: []string{WarnConstantCondition}},
+		{name: "W0504 ignored", src: "while (!nil) print 1; /* vet:ignore W0504 */\n"},
+		{name: "W0505", src: "var x = 1;\n{ var x = 2; print x; }\n", want: []string{WarnShadowedVariable}},
+		{name: "W0505 ignored", src: "var x = 1;\n{ var x = 2; print x; } // vet:ignore W0505\n"},
+		{name: "W0506", src: "print 1 == \"1\";\n", want: []string{WarnMixedComparison}},
+		{name: "W0506 ignored", src: "print 1 == \"1\"; // vet:ignore W0506\n"},
+		{name: "W0507", src: "print 1 / 0;\n", want: []string{WarnDivisionByZero}},
+		{name: "W0507 ignored", src: "print 1 / 0; // vet:ignore W0507\n"},
+
+		{
+			name: "ignore all",
+			src:  "// vet:ignore\nprint 1 / 0 == \"a\";\nprint 1 / 0;\n",
+			want: []string{WarnDivisionByZero},
+		},
+		{
+			name: "ignore other code",
+			src:  "print 1 / 0; // vet:ignore W0503, W0506\n",
+			want: []string{WarnDivisionByZero},
+		},
+		{
+			name: "ignore after multi-line comment",
+			src:  "/* vet:ignore\n   W0507 */\nprint 1 / 0;\nprint 2 / 0;\n",
+			want: []string{WarnDivisionByZero},
+		},
+		{
+			name: "ignore unknown code",
+			src:  "print 1 / 0; // vet:ignore W9999\n",
+			want: []string{WarnDivisionByZero, ErrUnknownRule},
+		},
+		{
+			name:     "disabled",
+			src:      "{ var x = 1 / 0; }\n",
+			disabled: []string{WarnUnusedVariable},
+			want:     []string{WarnDivisionByZero},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			disabled := make(map[string]bool)
+			for _, code := range test.disabled {
+				disabled[code] = true
+			}
+			file := NewFileSet().AddFile(test.name, -1, len(test.src))
+			var got []string
+			for _, d := range Vet(file, strings.NewReader(test.src), disabled) {
+				got = append(got, d.Code)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("Vet(%q) = %v, want %v", test.src, got, test.want)
+			}
+		})
+	}
+}
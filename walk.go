@@ -0,0 +1,93 @@
+package main
+
+import "fmt"
+
+// Inspect traverses the syntax tree rooted at node in depth-first order. It
+// calls f for each node before visiting its children, which are skipped if f
+// returns false. Missing optional children, such as an if statement's else
+// branch, are not visited. It is modelled on go/ast.Inspect.
+func Inspect(node Node, f func(Node) bool) {
+	if !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	// Expressions
+	case *AssignExpr:
+		Inspect(n.value, f)
+	case *BadExpr:
+	case *BinaryExpr:
+		Inspect(n.lhs, f)
+		Inspect(n.rhs, f)
+	case *CallExpr:
+		Inspect(n.callee, f)
+		for _, arg := range n.args {
+			Inspect(arg, f)
+		}
+	case *ConditionalExpr:
+		Inspect(n.condition, f)
+		Inspect(n.thenBranch, f)
+		Inspect(n.elseBranch, f)
+	case *GetExpr:
+		Inspect(n.object, f)
+	case *GroupingExpr:
+		Inspect(n.expr, f)
+	case *InterpolationExpr:
+		for _, part := range n.parts {
+			Inspect(part, f)
+		}
+	case *LiteralExpr:
+	case *LogicalExpr:
+		Inspect(n.lhs, f)
+		Inspect(n.rhs, f)
+	case *SetExpr:
+		Inspect(n.object, f)
+		Inspect(n.value, f)
+	case *SuperExpr:
+	case *ThisExpr:
+	case *UnaryExpr:
+		Inspect(n.rhs, f)
+	case *VariableExpr:
+
+	// Statements
+	case *BlockStmt:
+		for _, stmt := range n.stmts {
+			Inspect(stmt, f)
+		}
+	case *ClassStmt:
+		if n.superclass != nil {
+			Inspect(n.superclass, f)
+		}
+		for _, method := range n.methods {
+			Inspect(method, f)
+		}
+	case *ExpressionStmt:
+		Inspect(n.expr, f)
+	case *FunctionStmt:
+		for _, stmt := range n.body {
+			Inspect(stmt, f)
+		}
+	case *IfStmt:
+		Inspect(n.condition, f)
+		Inspect(n.thenBranch, f)
+		if n.elseBranch != nil {
+			Inspect(n.elseBranch, f)
+		}
+	case *PrintStmt:
+		Inspect(n.expr, f)
+	case *ReturnStmt:
+		if n.value != nil {
+			Inspect(n.value, f)
+		}
+	case *VarStmt:
+		if n.initializer != nil {
+			Inspect(n.initializer, f)
+		}
+	case *WhileStmt:
+		Inspect(n.condition, f)
+		Inspect(n.body, f)
+
+	default:
+		panic(fmt.Sprintf("unexpected node type %T", n))
+	}
+}